@@ -0,0 +1,116 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// IPNotAllowedError is returned when a connection would be made to an IP address which is not
+// included in ClientConfig.AllowedIPs.
+type IPNotAllowedError struct {
+	// Host is the hostname or address which was going to be connected to.
+	Host string
+
+	// IP is the address which was rejected.
+	IP netip.Addr
+}
+
+func (e *IPNotAllowedError) Error() string {
+	if e.Host == e.IP.String() {
+		return fmt.Sprintf("%s is not an allowed IP", e.IP)
+	}
+	return fmt.Sprintf("%s resolved to %s which is not an allowed IP", e.Host, e.IP)
+}
+
+// parseAllowedIPs reads IP addresses and CIDR ranges. A nil slice is returned when every
+// address is allowed.
+func parseAllowedIPs(allowed []string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, value := range allowed {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid AllowedIPs range: %w", err)
+			}
+			out = append(out, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AllowedIPs address: %w", err)
+		}
+		out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return out, nil
+}
+
+func ipAllowed(allowed []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectOutboundIPRange resolves hostname and returns an IPNotAllowedError if any of its
+// addresses are not allowed.
+func rejectOutboundIPRange(ctx context.Context, allowed []netip.Prefix, hostname string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	host := hostOnly(hostname)
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !ipAllowed(allowed, addr) {
+			return &IPNotAllowedError{Host: host, IP: addr.Unmap()}
+		}
+	}
+	return nil
+}
+
+// allowedIPsControl returns a net.Dialer.Control function which rejects connections to
+// addresses outside of allowed. It runs after hostnames are resolved, so it covers the control
+// connection as well as data connections to addresses handed back by the server (PASV).
+func allowedIPsControl(allowed []netip.Prefix, hostname string) func(network, address string, c syscall.RawConn) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	host := hostOnly(hostname)
+	return func(network, address string, _ syscall.RawConn) error {
+		addrPort, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return fmt.Errorf("parsing dial address %s: %w", address, err)
+		}
+		if addr := addrPort.Addr().Unmap(); !ipAllowed(allowed, addr) {
+			return &IPNotAllowedError{Host: host, IP: addr}
+		}
+		return nil
+	}
+}
+
+// hostOnly returns hostname without a port.
+func hostOnly(hostname string) string {
+	host, _, err := net.SplitHostPort(hostname)
+	if err != nil {
+		return strings.Trim(hostname, "[]")
+	}
+	return host
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAllowedIPs(t *testing.T) {
+	allowed, err := parseAllowedIPs(nil)
+	require.NoError(t, err)
+	require.Nil(t, allowed)
+
+	allowed, err = parseAllowedIPs([]string{"10.1.2.3", " 192.168.0.0/16", "", "2001:db8::/32"})
+	require.NoError(t, err)
+	require.Len(t, allowed, 3)
+
+	require.True(t, ipAllowed(allowed, netip.MustParseAddr("10.1.2.3")))
+	require.False(t, ipAllowed(allowed, netip.MustParseAddr("10.1.2.4")))
+	require.True(t, ipAllowed(allowed, netip.MustParseAddr("192.168.45.6")))
+	require.True(t, ipAllowed(allowed, netip.MustParseAddr("::ffff:192.168.45.6")))
+	require.True(t, ipAllowed(allowed, netip.MustParseAddr("2001:db8::1")))
+	require.False(t, ipAllowed(allowed, netip.MustParseAddr("2001:db9::1")))
+
+	_, err = parseAllowedIPs([]string{"10.0.0.0/40"})
+	require.ErrorContains(t, err, "invalid AllowedIPs range")
+
+	_, err = parseAllowedIPs([]string{"example.com"})
+	require.ErrorContains(t, err, "invalid AllowedIPs address")
+}
+
+func TestRejectOutboundIPRange(t *testing.T) {
+	ctx := context.Background()
+	allowed, err := parseAllowedIPs([]string{"127.0.0.0/8", "::1"})
+	require.NoError(t, err)
+
+	require.NoError(t, rejectOutboundIPRange(ctx, nil, "192.0.2.1:21"))
+	require.NoError(t, rejectOutboundIPRange(ctx, allowed, "127.0.0.1:2121"))
+	require.NoError(t, rejectOutboundIPRange(ctx, allowed, "localhost"))
+
+	err = rejectOutboundIPRange(ctx, allowed, "192.0.2.1:21")
+	var ipErr *IPNotAllowedError
+	require.True(t, errors.As(err, &ipErr))
+	require.Equal(t, "192.0.2.1", ipErr.Host)
+	require.Equal(t, netip.MustParseAddr("192.0.2.1"), ipErr.IP)
+	require.Equal(t, "192.0.2.1 is not an allowed IP", err.Error())
+}
+
+func TestAllowedIPsControl(t *testing.T) {
+	require.Nil(t, allowedIPsControl(nil, "ftp.example.com:21"))
+
+	allowed, err := parseAllowedIPs([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	control := allowedIPsControl(allowed, "ftp.example.com:21")
+	require.NoError(t, control("tcp4", "10.20.30.40:30001", nil))
+
+	// e.g. a passive mode address handed back by the server
+	err = control("tcp4", "203.0.113.9:30001", nil)
+	var ipErr *IPNotAllowedError
+	require.True(t, errors.As(err, &ipErr))
+	require.Equal(t, "ftp.example.com resolved to 203.0.113.9 which is not an allowed IP", err.Error())
+}
@@ -7,19 +7,26 @@ package go_ftp
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/textproto"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/jlaffaye/ftp"
+	"golang.org/x/time/rate"
 )
 
 type ClientConfig struct {
@@ -29,132 +36,229 @@ type ClientConfig struct {
 
 	Timeout     time.Duration
 	DisableEPSV bool
-	CAFile      string
+
+	// AllowedIPs are IP addresses and CIDR ranges (e.g. 10.0.0.0/8) the client may connect to.
+	// Connections to any other address, including data connections to an address returned by
+	// the server, fail with an *IPNotAllowedError. All addresses are allowed when empty.
+	AllowedIPs []string
+
+	// TLSMode sets how connections are protected with TLS. When empty, implicit TLS is used
+	// if any TLS setting (CAFile, TLSConfig, ClientCertFile or pins) is set and plaintext FTP
+	// otherwise.
+	TLSMode TLSMode
+
+	// CAFile is a path of PEM encoded certificates to trust in addition to the system roots.
+	CAFile string
+
+	// ClientCertFile and ClientKeyFile are paths of a PEM encoded certificate and private key
+	// presented to servers which require mutual TLS. When ClientKeyFile is empty ClientCertFile
+	// is read as a PKCS#12 (.p12 / .pfx) bundle protected by ClientCertPassword.
+	ClientCertFile     string
+	ClientKeyFile      string
+	ClientCertPassword string
+
+	// PinnedPublicKeys are base64 encoded SHA-256 hashes of a server certificate's public key
+	// (SubjectPublicKeyInfo), optionally prefixed with "sha256//". PinnedCertificates are hex
+	// encoded SHA-256 fingerprints of a server certificate. When pins are set the server's leaf
+	// certificate must match one of them in addition to passing the usual verification.
+	PinnedPublicKeys   []string
+	PinnedCertificates []string
 
 	TLSConfig *tls.Config
+
+	// MinConnections is the number of connections kept open to the server, even when they
+	// are idle. They are opened by NewClient.
+	MinConnections int
+
+	// MaxConnections limits how many connections are open to the server at once, which is how
+	// many operations (including open Readers) can run concurrently. Operations wait for a
+	// connection to be returned once the limit is reached, for up to Timeout when they're
+	// called without a context (or with DefaultContext) and until their context is done
+	// otherwise. The default is 1, so a Reader which isn't closed makes the other methods
+	// without a context fail with ErrPoolTimeout.
+	MaxConnections int
+
+	// IdleTimeout closes connections which have not been used for the duration, except for
+	// MinConnections. Zero keeps idle connections open.
+	IdleTimeout time.Duration
+
+	// Retry retries operations which fail with transient errors. Operations are only tried
+	// once by default.
+	Retry RetryPolicy
+
+	// Logger records connections, logins, directory changes and transfers with their sizes
+	// and durations. Failures are logged at warn level. Passwords are never logged.
+	Logger *slog.Logger
+
+	// LogTranscript logs each command sent and reply received on control connections to
+	// Logger at debug level. The argument of PASS is redacted.
+	LogTranscript bool
+
+	// Metrics receives measurements of operations, transfers and connections. Nothing is
+	// recorded when nil.
+	Metrics Metrics
+
+	// RateLimit limits the combined transfer rate of all uploads and downloads, which can be
+	// overridden for a transfer with DownloadRateLimit or UploadRateLimit. Transfers are not
+	// limited by default.
+	RateLimit RateLimit
 }
 
 type Client interface {
 	Ping() error
 	Close() error
 
-	Open(path string) (*File, error)
-	Reader(path string) (*File, error)
+	Open(path string, opts ...ReadOption) (*File, error)
+	Reader(path string, opts ...ReadOption) (*File, error)
+
+	Stat(path string) (fs.FileInfo, error)
 
 	Delete(path string) error
-	UploadFile(path string, contents io.ReadCloser) error
+	UploadFile(path string, contents io.ReadCloser, opts ...UploadOption) error
+	AppendFile(path string, contents io.ReadCloser, opts ...UploadOption) error
+
+	Create(path string, opts ...UploadOption) (io.WriteCloser, error)
+	OpenFile(path string, flag int, opts ...UploadOption) (io.WriteCloser, error)
+
+	Rename(oldPath, newPath string, opts ...RenameOption) error
+	Move(oldPath, newPath string, opts ...RenameOption) error
+
+	Mkdir(path string) error
+	MkdirAll(path string) error
+	RemoveDir(path string) error
+	RemoveAll(path string) error
 
+	ReadDir(dir string) ([]fs.DirEntry, error)
 	ListFiles(dir string) ([]string, error)
 	Walk(dir string, fn fs.WalkDirFunc) error
 }
 
-func NewClient(cfg ClientConfig) (Client, error) {
-	cc := &client{
-		cfg: cfg,
-	}
+// ClientContext is a Client whose methods also accept a context.Context. Cancelling the context
+// aborts the FTP control and data connections of an in-flight operation, including transfers
+// which are in progress.
+type ClientContext interface {
+	Client
 
-	// if err := rejectOutboundIPRange(cfg.SplitAllowedIPs(), cfg.FTP.Hostname); err != nil {
-	// 	return nil, fmt.Errorf("ftp: %s is not whitelisted: %v", cfg.FTP.Hostname, err)
-	// }
+	PingContext(ctx context.Context) error
 
-	_, err := cc.connection() // initial connection
-	if err != nil {
-		return cc, fmt.Errorf("ftp connect: %v", err)
-	}
-	return cc, nil
+	OpenContext(ctx context.Context, path string, opts ...ReadOption) (*File, error)
+	ReaderContext(ctx context.Context, path string, opts ...ReadOption) (*File, error)
+
+	StatContext(ctx context.Context, path string) (fs.FileInfo, error)
+
+	DeleteContext(ctx context.Context, path string) error
+	UploadFileContext(ctx context.Context, path string, contents io.ReadCloser, opts ...UploadOption) error
+	AppendFileContext(ctx context.Context, path string, contents io.ReadCloser, opts ...UploadOption) error
+
+	CreateContext(ctx context.Context, path string, opts ...UploadOption) (io.WriteCloser, error)
+	OpenFileContext(ctx context.Context, path string, flag int, opts ...UploadOption) (io.WriteCloser, error)
+
+	RenameContext(ctx context.Context, oldPath, newPath string, opts ...RenameOption) error
+	MoveContext(ctx context.Context, oldPath, newPath string, opts ...RenameOption) error
+
+	MkdirContext(ctx context.Context, path string) error
+	MkdirAllContext(ctx context.Context, path string) error
+	RemoveDirContext(ctx context.Context, path string) error
+	RemoveAllContext(ctx context.Context, path string) error
+
+	ReadDirContext(ctx context.Context, dir string) ([]fs.DirEntry, error)
+	ListFilesContext(ctx context.Context, dir string) ([]string, error)
+	WalkContext(ctx context.Context, dir string, fn fs.WalkDirFunc) error
 }
 
-type client struct {
-	conn *ftp.ServerConn
-	cfg  ClientConfig
-	mu   sync.Mutex // protects all read/write methods
+func NewClient(cfg ClientConfig) (Client, error) {
+	return NewClientContext(context.Background(), cfg)
 }
 
-// connection returns an ftp.ServerConn which is connected to the remote server.
-// This function will attempt to establish a new connection if none exists already.
-//
-// connection must be called within a mutex lock as the underlying FTP client is not
-// goroutine-safe.
-func (cc *client) connection() (*ftp.ServerConn, error) {
-	if cc == nil {
-		return nil, errors.New("nil client or config")
+// NewClientContext returns a ClientContext connected to the FTP server. ctx is used for the
+// initial connection.
+func NewClientContext(ctx context.Context, cfg ClientConfig) (ClientContext, error) {
+	allowedIPs, err := parseAllowedIPs(cfg.AllowedIPs)
+	if err != nil {
+		return nil, fmt.Errorf("ftp: %w", err)
 	}
-
-	if cc.conn != nil {
-		// Verify the connection works and f not drop through and reconnect
-		if err := cc.conn.NoOp(); err == nil {
-			return cc.conn, nil
-		} else {
-			// Our connection is having issues, so retry connecting
-			cc.conn.Quit()
-		}
+	if err := rejectOutboundIPRange(ctx, allowedIPs, cfg.Hostname); err != nil {
+		return nil, fmt.Errorf("ftp: %s is not allowed: %w", cfg.Hostname, err)
 	}
 
-	// Setup our FTP connection
-	opts := []ftp.DialOption{
-		ftp.DialWithTimeout(cc.cfg.Timeout),
-		ftp.DialWithDisabledEPSV(cc.cfg.DisableEPSV),
-	}
-	tlsOpt, err := tlsDialOption(cc.cfg.TLSConfig, cc.cfg.CAFile)
-	if err != nil {
-		return nil, err
+	cc := &client{
+		cfg:         cfg,
+		allowedIPs:  allowedIPs,
+		rateLimiter: cfg.RateLimit.limiter(),
 	}
-	if tlsOpt != nil {
-		opts = append(opts, *tlsOpt)
+	cc.pool = newPool(cfg, cc.dial)
+	cc.pool.reconnected = func() {
+		cc.metrics().IncReconnects(cfg.Hostname)
 	}
 
-	// Make the first connection
-	conn, err := ftp.Dial(cc.cfg.Hostname, opts...)
+	err = cc.pool.fill(ctx, max(cfg.MinConnections, 1)) // initial connection
 	if err != nil {
-		return nil, err
-	}
-	if err := conn.Login(cc.cfg.Username, cc.cfg.Password); err != nil {
-		return nil, err
+		return cc, fmt.Errorf("ftp connect: %v", contextError(ctx, err))
 	}
-	cc.conn = conn
+	return cc, nil
+}
 
-	return cc.conn, nil
+type client struct {
+	cfg        ClientConfig
+	allowedIPs []netip.Prefix
+	pool       *pool
+
+	rateLimiter *rate.Limiter // shared by transfers, nil without a limit
 }
 
-func tlsDialOption(conf *tls.Config, caFilePath string) (*ftp.DialOption, error) {
-	if caFilePath == "" {
-		return nil, nil
-	}
-	bs, err := os.ReadFile(caFilePath)
+var _ ClientContext = (&client{})
+
+// acquire returns a connection whose network operations are aborted once ctx is done.
+// The returned release function must be called when the caller is finished with the
+// connection, it annotates *errp when ctx caused the operation to fail.
+func (cc *client) acquire(ctx context.Context) (*serverConn, func(errp *error), error) {
+	conn, err := cc.connection(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("tlsDialOption: failed to read %s: %v", caFilePath, err)
-	}
-	pool, err := x509.SystemCertPool()
-	if pool == nil || err != nil {
-		pool = x509.NewCertPool()
-	}
-	ok := pool.AppendCertsFromPEM(bs)
-	if !ok {
-		return nil, fmt.Errorf("tlsDialOption: problem with AppendCertsFromPEM from %s", caFilePath)
+		return nil, nil, contextError(ctx, err)
 	}
-	if conf == nil {
-		conf = &tls.Config{
-			MinVersion: tls.VersionTLS12,
+	stop := conn.watch(ctx)
+
+	return conn, func(errp *error) {
+		if !stop() {
+			// ctx was done while the connection was in use, so make sure it's discarded
+			conn.abort()
 		}
-	}
-	conf.RootCAs = pool
+		var netErr net.Error
+		if errors.As(*errp, &netErr) {
+			// A timeout or dropped connection leaves replies in an unknown state
+			conn.abort()
+		}
+		cc.pool.put(conn)
+		*errp = contextError(ctx, *errp)
+	}, nil
+}
 
-	opt := ftp.DialWithTLS(conf)
-	return &opt, nil
+// connection returns a serverConn which is connected to the remote server. Idle connections
+// are reused after a health check and new connections are established when none are available.
+//
+// Each connection is used by one caller at a time as the underlying FTP client is not
+// goroutine-safe, so callers must return the connection to the pool when finished.
+func (cc *client) connection(ctx context.Context) (*serverConn, error) {
+	if cc == nil {
+		return nil, errors.New("nil client or config")
+	}
+	return cc.pool.get(ctx)
 }
 
 func (cc *client) Ping() error {
+	return cc.PingContext(DefaultContext())
+}
+
+func (cc *client) PingContext(ctx context.Context) (err error) {
 	if cc == nil {
 		return errors.New("nil FTP client")
 	}
 
-	cc.mu.Lock()
-	defer cc.mu.Unlock()
-
-	conn, err := cc.connection()
+	conn, release, err := cc.acquire(ctx)
 	if err != nil {
 		return fmt.Errorf("get connection for ping: %w", err)
 	}
+	defer release(&err)
 
 	err = conn.NoOp()
 	if err != nil {
@@ -163,19 +267,14 @@ func (cc *client) Ping() error {
 	return nil
 }
 
+// Close closes the idle connections to the server. Connections used by open Readers are
+// closed once the File is closed. The client reconnects if it is used afterwards.
 func (cc *client) Close() error {
-	if cc == nil || cc.conn == nil {
+	if cc == nil || cc.pool == nil {
 		return nil
 	}
 
-	cc.mu.Lock()
-	defer cc.mu.Unlock()
-
-	conn, err := cc.connection()
-	if err != nil {
-		return fmt.Errorf("get connection for close: %w", err)
-	}
-	err = conn.Quit()
+	err := cc.pool.close()
 	if err != nil {
 		return fmt.Errorf("close: %w", err)
 	}
@@ -184,14 +283,41 @@ func (cc *client) Close() error {
 
 // Open will return the contents at path and consume the entire file contents.
 // WARNING: This method can use a lot of memory by consuming the entire file into memory.
-func (cc *client) Open(path string) (*File, error) {
-	cc.mu.Lock()
-	defer cc.mu.Unlock()
+func (cc *client) Open(path string, opts ...ReadOption) (*File, error) {
+	return cc.OpenContext(DefaultContext(), path, opts...)
+}
+
+// OpenContext is Open with a context. Cancelling ctx aborts the download.
+func (cc *client) OpenContext(ctx context.Context, path string, opts ...ReadOption) (_ *File, err error) {
+	defer cc.done(&err, "open", path, time.Now())
+
+	if options := newReadOptions(opts); options.resumeRetries > 0 {
+		// Resuming needs to reconnect, which Reader takes care of
+		file, err := cc.reader(ctx, path, options)
+		if err != nil {
+			return nil, err
+		}
+		file.Contents, err = readResponse(file.Contents)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s failed: %w", path, err)
+		}
+		return file, nil
+	}
 
-	conn, err := cc.connection()
+	var file *File
+	err = cc.retry(ctx, func() (err error) {
+		file, err = cc.open(ctx, path, newReadOptions(opts))
+		return err
+	})
+	return file, err
+}
+
+func (cc *client) open(ctx context.Context, path string, options readOptions) (_ *File, err error) {
+	conn, release, err := cc.acquire(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer release(&err)
 
 	dir, filename := filepath.Split(path)
 	if dir != "" {
@@ -203,6 +329,7 @@ func (cc *client) Open(path string) (*File, error) {
 		defer func(previous string) {
 			// Return to our previous directory when initially called
 			if cleanupErr := conn.ChangeDir(previous); cleanupErr != nil {
+				conn.abort() // don't reuse a connection in an unknown directory
 				err = fmt.Errorf("FTP: problem with readFiles: %w", cleanupErr)
 			}
 		}(wd)
@@ -213,117 +340,496 @@ func (cc *client) Open(path string) (*File, error) {
 		}
 	}
 
+	file := newFile(conn, filename)
+
 	resp, err := conn.Retr(filename)
 	if err != nil {
-		return nil, fmt.Errorf("retrieving %s failed: %w", path, err)
+		return nil, fmt.Errorf("retrieving %s failed: %w", path, retrError(conn, filename, err))
 	}
 
-	data, err := readResponse(resp)
+	resp = throttleCloser(ctx, resp, cc.limiter(options.rateLimit))
+	if options.progress != nil {
+		resp = startProgress(path, fileSize(file), *options.progress).reader(resp, true)
+	}
+
+	file.Contents, err = readResponse(resp)
 	if err != nil {
 		return nil, fmt.Errorf("reading %s failed: %w", path, err)
 	}
 
-	return &File{
-		Filename: filepath.Base(path),
-		Contents: data,
-	}, nil
+	return file, nil
 }
 
 // Reader will open the file at path and provide a reader to access its contents.
 // Callers need to close the returned Contents.
 //
 // Callers should be aware that network errors while reading can occur since contents
-// are streamed from the FTP server. Having multiple open readers is not supported.
-func (cc *client) Reader(path string) (*File, error) {
-	cc.mu.Lock()
-	defer cc.mu.Unlock()
+// are streamed from the FTP server. Each open Reader uses one of ClientConfig.MaxConnections
+// until its Contents are read to the end or closed.
+func (cc *client) Reader(path string, opts ...ReadOption) (*File, error) {
+	return cc.ReaderContext(DefaultContext(), path, opts...)
+}
+
+// ReaderContext is Reader with a context. ctx applies until the returned File is closed,
+// so cancelling it aborts reading Contents.
+func (cc *client) ReaderContext(ctx context.Context, path string, opts ...ReadOption) (_ *File, err error) {
+	defer cc.doneOpen(&err, "reader", path, time.Now())
+
+	return cc.reader(ctx, path, newReadOptions(opts))
+}
+
+func (cc *client) reader(ctx context.Context, path string, options readOptions) (_ *File, err error) {
+	limiter := cc.limiter(options.rateLimit)
 
-	conn, err := cc.connection()
+	if options.randomAccess != nil {
+		info, err := cc.StatContext(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		contents := newRandomReader(info.Size(), *options.randomAccess, options.resumeRetries, func(offset int64) (io.ReadCloser, error) {
+			return cc.retr(ctx, path, offset, nil, limiter)
+		})
+		return &File{
+			Filename: filepath.Base(path),
+			Contents: contents,
+			ModTime:  info.ModTime(),
+			fileinfo: info,
+		}, nil
+	}
+
+	file := &File{}
+	var contents io.ReadCloser
+	err = cc.retry(ctx, func() (err error) {
+		contents, err = cc.retr(ctx, path, 0, file, limiter)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	file := &File{
-		Filename: filepath.Base(path),
+	if options.resumeRetries > 0 {
+		contents = &resumingReader{
+			ctx:     ctx,
+			rc:      contents,
+			retries: options.resumeRetries,
+			backoff: cc.cfg.Retry.backoff,
+			open: func(offset int64) (io.ReadCloser, error) {
+				return cc.retr(ctx, path, offset, nil, limiter)
+			},
+		}
+	}
+	if options.progress != nil {
+		contents = startProgress(path, fileSize(file), *options.progress).reader(contents, true)
 	}
+	file.Contents = contents
+	return file, nil
+}
+
+// retr starts downloading path from offset. The connection is released once the returned
+// reader is read to the end or closed. When file is non-nil its metadata is filled in.
+// Reads are throttled by limiter when it's non-nil.
+func (cc *client) retr(ctx context.Context, path string, offset int64, file *File, limiter *rate.Limiter) (_ io.ReadCloser, err error) {
+	conn, release, err := cc.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// On success the connection is released once Contents are done
+		if err != nil {
+			release(&err)
+		}
+	}()
 
 	dir, filename := filepath.Split(path)
+	var wd string
 	if dir != "" {
 		// Jump to previous directory after command is done
-		wd, err := conn.CurrentDir()
+		wd, err = conn.CurrentDir()
 		if err != nil {
 			return nil, err
 		}
 
-		// On file Close move back to the directory we were previously in
-		file.cleanup = func() error {
-			err := conn.ChangeDir(wd)
-			if err != nil {
-				return fmt.Errorf("returning to %s failed: %w", wd, err)
-			}
-			return nil
-		}
-
 		// Move into directory to run the command
 		if err := conn.ChangeDir(dir); err != nil {
 			return nil, err
 		}
 	}
 
-	resp, err := conn.Retr(filename)
+	if file != nil {
+		*file = *newFile(conn, filename)
+	}
+
+	// Move back to the directory we were previously in once the connection is done
+	returnToDir := func() error {
+		if wd == "" {
+			return nil
+		}
+		err := conn.ChangeDir(wd)
+		if err != nil {
+			// Don't reuse a connection in an unknown directory
+			conn.abort()
+			return fmt.Errorf("returning to %s failed: %w", wd, err)
+		}
+		return nil
+	}
+
+	resp, err := conn.RetrFrom(filename, uint64(offset))
 	if err != nil {
-		return nil, fmt.Errorf("retrieving %s failed: %w", path, err)
+		return nil, errors.Join(fmt.Errorf("retrieving %s failed: %w", path, retrError(conn, filename, err)), returnToDir())
 	}
-	file.Contents = resp
 
-	prev := file.cleanup
-	file.cleanup = func() error {
-		if resp != nil {
-			if err := resp.Close(); err != nil {
+	done := sync.OnceValue(func() (err error) {
+		defer release(&err)
+
+		if err := resp.Close(); err != nil {
+			// A reply from the server, e.g. for a transfer which was stopped early, leaves the
+			// connection usable. Otherwise it's in an unknown state.
+			var tpErr *textproto.Error
+			if !errors.As(err, &tpErr) {
+				conn.abort()
 				return fmt.Errorf("closing RETR %s response failed: %w", path, err)
 			}
+			return errors.Join(fmt.Errorf("closing RETR %s response failed: %w", path, err), returnToDir())
+		}
+		return returnToDir()
+	})
+
+	return &releaseReader{
+		Reader:  throttle(ctx, &contextReader{ctx: ctx, ReadCloser: resp}, limiter),
+		release: done,
+	}, nil
+}
+
+// newFile returns a File for filename in the current directory of conn with its metadata,
+// which is left empty when the server does not provide it. The parent directory isn't listed
+// like Stat does as that's slow for directories with many files.
+func newFile(conn *serverConn, filename string) *File {
+	file := &File{
+		Filename: filepath.Base(filename),
+		ModTime:  time.Now().UTC(),
+	}
+	entry, err := conn.GetEntry(filename)
+	if err == nil {
+		entry.Name = file.Filename
+	} else {
+		entry = sizeEntry(conn, filename)
+	}
+	if entry != nil {
+		file.fileinfo = newFileInfo(entry)
+		if !entry.Time.IsZero() {
+			file.ModTime = entry.Time
 		}
-		return prev()
 	}
+	return file
+}
 
-	return file, nil
+// retrError looks up filename after RETR failed with a reply which doesn't say why, as servers
+// often answer "file unavailable" when it's missing, so the error matches fs.ErrNotExist then.
+func retrError(conn *serverConn, filename string, err error) error {
+	var tpErr *textproto.Error
+	if !errors.As(err, &tpErr) || replyError(tpErr.Code, tpErr.Msg) != nil {
+		return err
+	}
+	if _, statErr := statEntry(conn, filename); errors.Is(statErr, fs.ErrNotExist) {
+		return &notExistError{err: err}
+	}
+	return err
+}
+
+// Stat returns information about the file or directory at path.
+//
+// Servers are asked with MLST when supported, otherwise SIZE and MDTM are used for files and
+// the parent directory is listed as a last resort.
+func (cc *client) Stat(path string) (fs.FileInfo, error) {
+	return cc.StatContext(DefaultContext(), path)
+}
+
+func (cc *client) StatContext(ctx context.Context, path string) (_ fs.FileInfo, err error) {
+	defer cc.done(&err, "stat", path, time.Now())
+
+	var info fs.FileInfo
+	err = cc.retry(ctx, func() (err error) {
+		info, err = cc.stat(ctx, path)
+		return err
+	})
+	return info, err
+}
+
+func (cc *client) stat(ctx context.Context, path string) (_ fs.FileInfo, err error) {
+	conn, release, err := cc.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get connection for stat: %w", err)
+	}
+	defer release(&err)
+
+	entry, err := statEntry(conn, path)
+	if err != nil {
+		return nil, err
+	}
+	return newFileInfo(entry), nil
+}
+
+func statEntry(conn *serverConn, path string) (*ftp.Entry, error) {
+	name := filepath.Base(path)
+
+	// MLST describes files and directories in one command
+	if entry, err := conn.GetEntry(path); err == nil {
+		entry.Name = name
+		return entry, nil
+	}
+
+	if conn.IsGetTimeSupported() {
+		if entry := sizeEntry(conn, path); entry != nil && !entry.Time.IsZero() {
+			return entry, nil
+		}
+	}
+
+	// Look for path in a listing of its parent directory
+	dir, _ := filepath.Split(strings.TrimSuffix(path, "/"))
+	if name == "/" || name == "." {
+		return &ftp.Entry{Name: name, Type: ftp.EntryTypeFolder}, nil
+	}
+	entries, err := conn.List(dir)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s failed: %w", path, err)
+	}
+	for _, entry := range entries {
+		if entry.Name == name {
+			return entry, nil
+		}
+	}
+	return nil, &PathError{Op: "stat", Path: path, Err: fs.ErrNotExist}
+}
+
+// sizeEntry describes the file at path with SIZE, which only works for files, and MDTM when
+// the server supports it. It returns nil when path isn't a file the server can size.
+func sizeEntry(conn *serverConn, path string) *ftp.Entry {
+	size, err := conn.FileSize(path)
+	if err != nil {
+		return nil
+	}
+	entry := &ftp.Entry{
+		Name: filepath.Base(path),
+		Type: ftp.EntryTypeFile,
+		Size: uint64(size),
+	}
+	if conn.IsGetTimeSupported() {
+		if modTime, err := conn.GetTime(path); err == nil {
+			entry.Time = modTime
+		}
+	}
+	return entry
 }
 
 func (cc *client) Delete(path string) error {
-	cc.mu.Lock()
-	defer cc.mu.Unlock()
+	return cc.DeleteContext(DefaultContext(), path)
+}
+
+func (cc *client) DeleteContext(ctx context.Context, path string) (err error) {
+	defer cc.done(&err, "delete", path, time.Now())
 
 	if path == "" || strings.HasSuffix(path, "/") {
 		return fmt.Errorf("FTP client: invalid path %v", path)
 	}
 
-	conn, err := cc.connection()
+	return cc.retry(ctx, func() error {
+		return cc.delete(ctx, path)
+	})
+}
+
+func (cc *client) delete(ctx context.Context, path string) (err error) {
+	conn, release, err := cc.acquire(ctx)
 	if err != nil {
 		return fmt.Errorf("get connection for delete: %w", err)
 	}
+	defer release(&err)
 
+	// Files which are already gone are fine, as long as the reply says so
 	err = conn.Delete(path)
-	if err != nil && !strings.Contains(err.Error(), "no such file or directory") {
+	if err != nil && !errors.Is(newPathError("delete", path, err), fs.ErrNotExist) {
 		return fmt.Errorf("delete %s failed: %w", path, err)
 	}
 	return nil
 }
 
+// UploadOption configures UploadFile.
+type UploadOption func(*uploadOptions)
+
+type uploadOptions struct {
+	createParentDirs bool
+	atomic           *AtomicUploadConfig
+	resume           bool
+	progress         *progressOptions
+	rateLimit        *RateLimit
+}
+
+// CreateParentDirs creates the directory of the uploaded file and any missing parents.
+// Without it uploads into a missing directory fail.
+func CreateParentDirs() UploadOption {
+	return func(o *uploadOptions) {
+		o.createParentDirs = true
+	}
+}
+
+func newUploadOptions(opts []UploadOption) uploadOptions {
+	var o uploadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
 // uploadFile saves the content of File at the given filename in the OutboundPath directory
 //
 // The File's contents will always be closed
-func (cc *client) UploadFile(path string, contents io.ReadCloser) (err error) {
+func (cc *client) UploadFile(path string, contents io.ReadCloser, opts ...UploadOption) error {
+	return cc.UploadFileContext(DefaultContext(), path, contents, opts...)
+}
+
+// UploadFileContext is UploadFile with a context. Cancelling ctx aborts the upload.
+func (cc *client) UploadFileContext(ctx context.Context, path string, contents io.ReadCloser, opts ...UploadOption) (err error) {
+	defer cc.done(&err, "upload", path, time.Now())
+
+	defer contents.Close()
+
+	options := newUploadOptions(opts)
+	if options.atomic != nil && options.resume {
+		return errors.New("upload: AtomicUpload and ResumeUpload can't be combined")
+	}
+	if options.progress != nil {
+		tracker := startProgress(path, uploadSize(contents), *options.progress)
+		defer func() { tracker.finish(err) }()
+		contents = tracker.reader(contents, false)
+	}
+	contents = throttleCloser(ctx, contents, cc.limiter(options.rateLimit))
+
+	upload := func() error {
+		return cc.store(ctx, path, options, func(conn *serverConn, filename string) error {
+			// Take the base of f.Filename and our (out of band) OutboundPath to avoid accepting a write like '../../../../etc/passwd'.
+			switch {
+			case options.atomic != nil:
+				return storAtomic(conn, filename, contents, *options.atomic)
+			case options.resume:
+				return storResume(conn, filename, contents)
+			}
+			return conn.Stor(filename, contents)
+		})
+	}
+
+	// Uploads can only be retried when the contents can be read again
+	seeker, ok := contents.(io.Seeker)
+	if !cc.cfg.Retry.RetryUploads || !ok {
+		return upload()
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return upload()
+	}
+
+	var attempted bool
+	return cc.retry(ctx, func() error {
+		if attempted {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return &noRetryError{err: fmt.Errorf("rewinding contents to retry upload: %w", err)}
+			}
+		}
+		attempted = true
+		return upload()
+	})
+}
+
+// AppendFile adds contents to the end of the file at path with APPE. The file is created
+// when it doesn't exist. The contents will always be closed.
+func (cc *client) AppendFile(path string, contents io.ReadCloser, opts ...UploadOption) error {
+	return cc.AppendFileContext(DefaultContext(), path, contents, opts...)
+}
+
+// AppendFileContext is AppendFile with a context. Cancelling ctx aborts the upload.
+func (cc *client) AppendFileContext(ctx context.Context, path string, contents io.ReadCloser, opts ...UploadOption) (err error) {
+	defer cc.done(&err, "append", path, time.Now())
+
 	defer contents.Close()
 
-	cc.mu.Lock()
-	defer cc.mu.Unlock()
+	options := newUploadOptions(opts)
+	if options.atomic != nil || options.resume {
+		return errors.New("append: only CreateParentDirs is supported")
+	}
+	contents = throttleCloser(ctx, contents, cc.limiter(options.rateLimit))
+
+	return cc.store(ctx, path, options, func(conn *serverConn, filename string) error {
+		return conn.Append(filename, contents)
+	})
+}
+
+// Create returns a writer which uploads everything written to it into path, replacing any
+// existing file. Close must be called to finish the upload and returns any error uploading.
+//
+// The writer uses a connection until it's closed.
+func (cc *client) Create(path string, opts ...UploadOption) (io.WriteCloser, error) {
+	return cc.CreateContext(DefaultContext(), path, opts...)
+}
+
+// CreateContext is Create with a context. ctx applies until the writer is closed.
+func (cc *client) CreateContext(ctx context.Context, path string, opts ...UploadOption) (io.WriteCloser, error) {
+	return cc.OpenFileContext(ctx, path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, opts...)
+}
+
+// OpenFile is like Create with flags from the os package. os.O_WRONLY is required along with
+// either os.O_TRUNC to replace the file or os.O_APPEND to add to it. The file must exist
+// unless os.O_CREATE is given, and must not exist when os.O_EXCL is also given.
+func (cc *client) OpenFile(path string, flag int, opts ...UploadOption) (io.WriteCloser, error) {
+	return cc.OpenFileContext(DefaultContext(), path, flag, opts...)
+}
+
+// OpenFileContext is OpenFile with a context. ctx applies until the writer is closed.
+func (cc *client) OpenFileContext(ctx context.Context, path string, flag int, opts ...UploadOption) (_ io.WriteCloser, err error) {
+	defer cc.doneOpen(&err, "openfile", path, time.Now())
 
-	conn, err := cc.connection()
+	options := newUploadOptions(opts)
+	if err := checkOpenFlags(flag, options); err != nil {
+		return nil, &PathError{Op: "open", Path: path, Err: err}
+	}
+
+	if flag&os.O_CREATE == 0 || flag&os.O_EXCL != 0 {
+		_, err := cc.StatContext(ctx, path)
+		switch {
+		case err == nil && flag&os.O_EXCL != 0:
+			return nil, &PathError{Op: "open", Path: path, Err: fs.ErrExist}
+		case err != nil && (flag&os.O_CREATE == 0 || !errors.Is(err, fs.ErrNotExist)):
+			return nil, err
+		}
+	}
+
+	limiter := cc.limiter(options.rateLimit)
+	return newFileWriter(func(r io.Reader) error {
+		r = throttle(ctx, r, limiter)
+		err := cc.store(ctx, path, options, func(conn *serverConn, filename string) error {
+			switch {
+			case flag&os.O_APPEND != 0:
+				return conn.Append(filename, r)
+			case options.atomic != nil:
+				return storAtomic(conn, filename, r, *options.atomic)
+			}
+			return conn.Stor(filename, r)
+		})
+		return newPathError("upload", path, err)
+	}), nil
+}
+
+// store changes into the directory of path and calls upload with the filename to write.
+func (cc *client) store(ctx context.Context, path string, options uploadOptions, upload func(conn *serverConn, filename string) error) (err error) {
+	conn, release, err := cc.acquire(ctx)
 	if err != nil {
 		return fmt.Errorf("getting connnection for upload: %w", err)
 	}
+	defer release(&err)
 
 	dir, filename := filepath.Split(path)
+	if dir != "" && options.createParentDirs {
+		if err := mkdirAll(conn, dir); err != nil {
+			return fmt.Errorf("creating dir for upload: %w", err)
+		}
+	}
 	if dir != "" {
 		// Jump to previous directory after command is done
 		wd, err := conn.CurrentDir()
@@ -333,6 +839,7 @@ func (cc *client) UploadFile(path string, contents io.ReadCloser) (err error) {
 		defer func(previous string) {
 			// Return to our previous directory when initially called
 			if cleanupErr := conn.ChangeDir(previous); cleanupErr != nil {
+				conn.abort() // don't reuse a connection in an unknown directory
 				err = fmt.Errorf("FTP: problem uploading %s: %w", filename, cleanupErr)
 			}
 		}(wd)
@@ -344,20 +851,300 @@ func (cc *client) UploadFile(path string, contents io.ReadCloser) (err error) {
 	}
 
 	// Write file contents into path
-	// Take the base of f.Filename and our (out of band) OutboundPath to avoid accepting a write like '../../../../etc/passwd'.
-	err = conn.Stor(filename, contents)
+	err = upload(conn, filename)
 	if err != nil {
 		return fmt.Errorf("upload %s (in %s) failed: %w", filename, dir, err)
 	}
 	return nil
 }
 
+// RenameOption configures Rename and Move.
+type RenameOption func(*renameOptions)
+
+type renameOptions struct {
+	overwrite bool
+}
+
+// OverwriteExisting replaces a file which already exists at the new path. Otherwise Rename and
+// Move fail with an error matching fs.ErrExist.
+func OverwriteExisting() RenameOption {
+	return func(o *renameOptions) {
+		o.overwrite = true
+	}
+}
+
+func newRenameOptions(opts []RenameOption) renameOptions {
+	var o renameOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Rename changes the path of a file or directory on the server with RNFR and RNTO.
+func (cc *client) Rename(oldPath, newPath string, opts ...RenameOption) error {
+	return cc.RenameContext(DefaultContext(), oldPath, newPath, opts...)
+}
+
+func (cc *client) RenameContext(ctx context.Context, oldPath, newPath string, opts ...RenameOption) (err error) {
+	defer cc.done(&err, "rename", oldPath, time.Now())
+
+	conn, release, err := cc.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection for rename: %w", err)
+	}
+	defer release(&err)
+
+	return rename(conn, oldPath, newPath, newRenameOptions(opts))
+}
+
+// Move renames a file or directory into another directory, which is created along with
+// any missing parents.
+func (cc *client) Move(oldPath, newPath string, opts ...RenameOption) error {
+	return cc.MoveContext(DefaultContext(), oldPath, newPath, opts...)
+}
+
+func (cc *client) MoveContext(ctx context.Context, oldPath, newPath string, opts ...RenameOption) (err error) {
+	defer cc.done(&err, "move", oldPath, time.Now())
+
+	conn, release, err := cc.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection for move: %w", err)
+	}
+	defer release(&err)
+
+	dir, _ := filepath.Split(newPath)
+	if err := mkdirAll(conn, dir); err != nil {
+		return fmt.Errorf("move %s: %w", oldPath, err)
+	}
+	return rename(conn, oldPath, newPath, newRenameOptions(opts))
+}
+
+func rename(conn *serverConn, oldPath, newPath string, opts renameOptions) error {
+	_, err := statEntry(conn, newPath)
+	exists := err == nil
+	if exists && !opts.overwrite {
+		return &PathError{Op: "rename", Path: newPath, Err: fs.ErrExist}
+	}
+
+	err = conn.Rename(oldPath, newPath)
+	if err != nil && exists && errors.Is(newPathError("rename", newPath, err), fs.ErrExist) {
+		// Some servers refuse to replace files, so remove the existing one and try again. The
+		// source has to exist, otherwise newPath would be removed for nothing.
+		if _, statErr := statEntry(conn, oldPath); statErr != nil {
+			return fmt.Errorf("rename %s to %s failed: %w", oldPath, newPath, err)
+		}
+		if deleteErr := conn.Delete(newPath); deleteErr != nil {
+			return fmt.Errorf("rename %s failed removing %s: %w", oldPath, newPath, deleteErr)
+		}
+		err = conn.Rename(oldPath, newPath)
+	}
+	if err != nil {
+		return fmt.Errorf("rename %s to %s failed: %w", oldPath, newPath, err)
+	}
+	return nil
+}
+
+// Mkdir creates the directory at path. Its parent directory must exist.
+func (cc *client) Mkdir(path string) error {
+	return cc.MkdirContext(DefaultContext(), path)
+}
+
+func (cc *client) MkdirContext(ctx context.Context, path string) (err error) {
+	defer cc.done(&err, "mkdir", path, time.Now())
+
+	conn, release, err := cc.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection for mkdir: %w", err)
+	}
+	defer release(&err)
+
+	err = conn.MakeDir(path)
+	if err != nil {
+		return fmt.Errorf("mkdir %s failed: %w", path, err)
+	}
+	return nil
+}
+
+// MkdirAll creates the directory at path along with any missing parents. Nothing is done
+// when the directory already exists.
+func (cc *client) MkdirAll(path string) error {
+	return cc.MkdirAllContext(DefaultContext(), path)
+}
+
+func (cc *client) MkdirAllContext(ctx context.Context, path string) (err error) {
+	defer cc.done(&err, "mkdir", path, time.Now())
+
+	conn, release, err := cc.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection for mkdir: %w", err)
+	}
+	defer release(&err)
+
+	return mkdirAll(conn, path)
+}
+
+// RemoveDir removes the empty directory at path.
+func (cc *client) RemoveDir(path string) error {
+	return cc.RemoveDirContext(DefaultContext(), path)
+}
+
+func (cc *client) RemoveDirContext(ctx context.Context, path string) (err error) {
+	defer cc.done(&err, "rmdir", path, time.Now())
+
+	conn, release, err := cc.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection for rmdir: %w", err)
+	}
+	defer release(&err)
+
+	err = conn.RemoveDir(path)
+	if err != nil {
+		return fmt.Errorf("rmdir %s failed: %w", path, err)
+	}
+	return nil
+}
+
+// RemoveAll removes path and everything it contains. Nothing is done when path does not exist.
+func (cc *client) RemoveAll(path string) error {
+	return cc.RemoveAllContext(DefaultContext(), path)
+}
+
+func (cc *client) RemoveAllContext(ctx context.Context, path string) (err error) {
+	defer cc.done(&err, "remove", path, time.Now())
+
+	if path == "" || path == "/" || path == "." {
+		return fmt.Errorf("FTP client: refusing to remove %q", path)
+	}
+
+	conn, release, err := cc.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection for remove: %w", err)
+	}
+	defer release(&err)
+
+	entry, err := statEntry(conn, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if entry.Type != ftp.EntryTypeFolder {
+		err = conn.Delete(path)
+		if err != nil {
+			return fmt.Errorf("remove %s failed: %w", path, err)
+		}
+		return nil
+	}
+
+	// RemoveDirRecur changes directories as it goes, so return to where we started
+	wd, err := conn.CurrentDir()
+	if err != nil {
+		return fmt.Errorf("current dir for remove: %w", err)
+	}
+	defer func(previous string) {
+		if cleanupErr := conn.ChangeDir(previous); cleanupErr != nil {
+			conn.abort() // don't reuse a connection in an unknown directory
+			err = fmt.Errorf("FTP: problem removing %s: %w", path, cleanupErr)
+		}
+	}(wd)
+
+	err = conn.RemoveDirRecur(path)
+	if err != nil {
+		return fmt.Errorf("remove %s failed: %w", path, err)
+	}
+	return nil
+}
+
+// mkdirAll creates dir and any of its missing parents.
+func mkdirAll(conn *serverConn, dir string) error {
+	dir = strings.TrimSuffix(dir, "/")
+	if dir == "" || dir == "." {
+		return nil
+	}
+
+	if entry, err := statEntry(conn, dir); err == nil {
+		if entry.Type != ftp.EntryTypeFolder {
+			return &PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+
+	if err := mkdirAll(conn, filepath.Dir(dir)); err != nil {
+		return err
+	}
+	if err := conn.MakeDir(dir); err != nil {
+		// Another caller could have created the directory in the meantime
+		if entry, statErr := statEntry(conn, dir); statErr == nil && entry.Type == ftp.EntryTypeFolder {
+			return nil
+		}
+		return fmt.Errorf("mkdir %s failed: %w", dir, err)
+	}
+	return nil
+}
+
+// ReadDir returns the entries of dir sorted by name.
+func (cc *client) ReadDir(dir string) ([]fs.DirEntry, error) {
+	return cc.ReadDirContext(DefaultContext(), dir)
+}
+
+func (cc *client) ReadDirContext(ctx context.Context, dir string) (_ []fs.DirEntry, err error) {
+	defer cc.done(&err, "readdir", dir, time.Now())
+
+	var entries []fs.DirEntry
+	err = cc.retry(ctx, func() (err error) {
+		entries, err = cc.readDir(ctx, dir)
+		return err
+	})
+	return entries, err
+}
+
+func (cc *client) readDir(ctx context.Context, dir string) (_ []fs.DirEntry, err error) {
+	conn, release, err := cc.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get connection for readdir: %w", err)
+	}
+	defer release(&err)
+
+	// LIST of a file describes the file, so make sure dir is a directory
+	entry, err := statEntry(conn, dir)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Type != ftp.EntryTypeFolder {
+		return nil, &PathError{Op: "readdir", Path: dir, Err: syscall.ENOTDIR}
+	}
+
+	entries, err := conn.List(dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s failed: %w", dir, err)
+	}
+	out := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Name == "." || entry.Name == ".." {
+			continue
+		}
+		out = append(out, Entry{fd: entry})
+	}
+	slices.SortFunc(out, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return out, nil
+}
+
 // ListFiles will return the paths of files within dir. Paths are returned as locations from dir,
 // so if dir is an absolute path the returned paths will be.
 //
 // Paths are matched in case-insensitive comparisons, but results are returned exactly as they
 // appear on the server.
 func (c *client) ListFiles(dir string) ([]string, error) {
+	return c.ListFilesContext(DefaultContext(), dir)
+}
+
+func (c *client) ListFilesContext(ctx context.Context, dir string) (_ []string, err error) {
+	defer c.done(&err, "list", dir, time.Now())
+
 	pattern := filepath.Clean(strings.TrimPrefix(dir, string(os.PathSeparator)))
 	switch {
 	case dir == "/":
@@ -373,7 +1160,19 @@ func (c *client) ListFiles(dir string) ([]string, error) {
 	}
 
 	var filenames []string
-	err := c.Walk(".", func(path string, d fs.DirEntry, err error) error {
+	err = c.retry(ctx, func() error {
+		// Start over when listing is retried
+		filenames = nil
+		return c.listFiles(ctx, dir, pattern, &filenames)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing %s failed: %w", dir, err)
+	}
+	return filenames, nil
+}
+
+func (c *client) listFiles(ctx context.Context, dir, pattern string, filenames *[]string) error {
+	return c.walk(ctx, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -391,31 +1190,47 @@ func (c *client) ListFiles(dir string) ([]string, error) {
 				if strings.HasPrefix(dir, "/") && !strings.HasPrefix(path, "/") {
 					path = "/" + path
 				}
-				filenames = append(filenames, path)
+				*filenames = append(*filenames, path)
 			} else {
 				// Fallback to Go logic of presenting the path
-				filenames = append(filenames, filepath.Join(dir, filepath.Base(path)))
+				*filenames = append(*filenames, filepath.Join(dir, filepath.Base(path)))
 			}
 		}
 		return err
 	})
-	if err != nil {
-		return nil, fmt.Errorf("listing %s failed: %w", dir, err)
-	}
-	return filenames, nil
 }
 
 // Walk will traverse dir and call fs.WalkDirFunc on each entry.
 //
 // Follow the docs for fs.WalkDirFunc for details on traversal. Walk accepts fs.SkipDir to not process directories.
 func (cc *client) Walk(dir string, fn fs.WalkDirFunc) error {
-	cc.mu.Lock()
-	defer cc.mu.Unlock()
+	return cc.WalkContext(DefaultContext(), dir, fn)
+}
 
-	conn, err := cc.connection()
+// WalkContext is Walk with a context. Cancelling ctx stops the traversal.
+func (cc *client) WalkContext(ctx context.Context, dir string, fn fs.WalkDirFunc) (err error) {
+	defer cc.done(&err, "walk", dir, time.Now())
+
+	// fn can't be called twice for the same entry, so only retry until walking has started
+	var started bool
+	return cc.retry(ctx, func() error {
+		err := cc.walk(ctx, dir, func(path string, d fs.DirEntry, err error) error {
+			started = true
+			return fn(path, d, err)
+		})
+		if err != nil && started {
+			return &noRetryError{err: err}
+		}
+		return err
+	})
+}
+
+func (cc *client) walk(ctx context.Context, dir string, fn fs.WalkDirFunc) (err error) {
+	conn, release, err := cc.acquire(ctx)
 	if err != nil {
 		return fmt.Errorf("get connection for walk: %w", err)
 	}
+	defer release(&err)
 
 	if dir != "" && dir != "." {
 		// Jump to previous directory after command is done
@@ -426,6 +1241,7 @@ func (cc *client) Walk(dir string, fn fs.WalkDirFunc) error {
 		defer func(previous string) {
 			// Return to our previous directory when initially called
 			if cleanupErr := conn.ChangeDir(previous); cleanupErr != nil {
+				conn.abort() // don't reuse a connection in an unknown directory
 				err = fmt.Errorf("FTP: problem walking %s: %w", dir, cleanupErr)
 			}
 		}(wd)
@@ -475,10 +1291,16 @@ func (cc *client) Walk(dir string, fn fs.WalkDirFunc) error {
 			}
 		}
 	}
+
+	// The walker stops without an error when listing a directory fails, which happens
+	// after ctx aborts the connection.
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("walking %s stopped: %w", dir, context.Cause(ctx))
+	}
 	return nil
 }
 
-func readResponse(resp *ftp.Response) (io.ReadCloser, error) {
+func readResponse(resp io.ReadCloser) (io.ReadCloser, error) {
 	defer resp.Close()
 
 	var buf bytes.Buffer
@@ -490,10 +1312,10 @@ func readResponse(resp *ftp.Response) (io.ReadCloser, error) {
 	//
 	// See https://github.com/moovfinancial/paygate/issues/494
 	if n == 0 && err == nil {
-		return io.NopCloser(&buf), nil
+		return bytesReadCloser{bytes.NewReader(buf.Bytes())}, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("n=%d error=%v", n, err)
 	}
-	return io.NopCloser(&buf), nil
+	return bytesReadCloser{bytes.NewReader(buf.Bytes())}, nil
 }
@@ -5,18 +5,26 @@
 package go_ftp_test
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	go_ftp "github.com/moov-io/go-ftp"
+	"github.com/moov-io/go-ftp/internal/ftptest"
 	mhttptest "github.com/moov-io/go-ftp/internal/httptest"
 
 	"github.com/stretchr/testify/require"
@@ -337,6 +345,916 @@ func TestClient__tlsDialOption(t *testing.T) {
 	require.NoError(t, client.Close())
 }
 
+func TestClientContext(t *testing.T) {
+	client, err := go_ftp.NewClientContext(context.Background(), go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+	})
+	require.NotNil(t, client)
+	require.NoError(t, err)
+	defer client.Close()
+
+	t.Run("cancel reader", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		file, err := client.ReaderContext(ctx, "/bigdata/large.txt")
+		require.NoError(t, err)
+
+		buf := make([]byte, 1024)
+		_, err = io.ReadFull(file, buf)
+		require.NoError(t, err)
+
+		cancel()
+
+		_, err = io.Copy(io.Discard, file)
+		require.ErrorIs(t, err, context.Canceled)
+		file.Close()
+
+		// The client reconnects after the aborted transfer
+		require.NoError(t, client.Ping())
+	})
+
+	t.Run("expired deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+		defer cancel()
+
+		_, err := client.OpenContext(ctx, "first.txt")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		err = client.UploadFileContext(ctx, "new.txt", io.NopCloser(strings.NewReader("data")))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		_, err = client.ListFilesContext(ctx, "/")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		require.NoError(t, client.PingContext(context.Background()))
+	})
+
+	t.Run("open", func(t *testing.T) {
+		file, err := client.OpenContext(context.Background(), "first.txt")
+		require.NoError(t, err)
+		t.Cleanup(func() { file.Close() })
+
+		var buf bytes.Buffer
+		io.Copy(&buf, file)
+		require.Equal(t, "hello world", strings.TrimSpace(buf.String()))
+	})
+}
+
+func TestClientPool(t *testing.T) {
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname:       "127.0.0.1:2121",
+		Username:       "admin",
+		Password:       "123456",
+		MaxConnections: 3,
+	})
+	require.NotNil(t, client)
+	require.NoError(t, err)
+	defer client.Close()
+
+	largerFileSize := size(t, filepath.Join("testdata", "ftp-server", "bigdata", "large.txt"))
+
+	// Hold open as many readers as there are connections
+	var files []*go_ftp.File
+	for i := 0; i < 3; i++ {
+		file, err := client.Reader("/bigdata/large.txt")
+		require.NoError(t, err)
+		files = append(files, file)
+	}
+
+	// Other operations wait until a connection is returned
+	cc := client.(go_ftp.ClientContext)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = cc.OpenContext(ctx, "first.txt")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Read each file in parallel
+	var g errgroup.Group
+	for _, file := range files {
+		g.Go(func() error {
+			defer file.Close()
+
+			n, err := io.Copy(io.Discard, file)
+			if err != nil {
+				return err
+			}
+			if int(n) != largerFileSize {
+				return fmt.Errorf("read %d bytes, expected %d", n, largerFileSize)
+			}
+			return nil
+		})
+	}
+	require.NoError(t, g.Wait())
+
+	file, err := client.Open("archive/old.txt")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	io.Copy(&buf, file)
+	require.Equal(t, "previous data", strings.TrimSpace(buf.String()))
+}
+
+func TestClient__TLSMode(t *testing.T) {
+	t.Run("explicit", func(t *testing.T) {
+		// The test server doesn't support TLS, so the client must not fall back to plaintext
+		client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+			Hostname: "127.0.0.1:2121",
+			Username: "admin",
+			Password: "123456",
+			TLSMode:  go_ftp.TLSModeExplicit,
+		})
+		require.ErrorContains(t, err, "AUTH TLS")
+		require.NotNil(t, client)
+		require.NoError(t, client.Close())
+	})
+
+	t.Run("none with TLSConfig", func(t *testing.T) {
+		client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+			Hostname:  "127.0.0.1:2121",
+			Username:  "admin",
+			Password:  "123456",
+			TLSMode:   go_ftp.TLSModeNone,
+			TLSConfig: &tls.Config{},
+		})
+		require.ErrorContains(t, err, "TLS settings provided with TLSMode none")
+		require.NotNil(t, client)
+	})
+
+	t.Run("implicit", func(t *testing.T) {
+		client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+			Hostname:  "127.0.0.1:2121",
+			Username:  "admin",
+			Password:  "123456",
+			Timeout:   5 * time.Second,
+			TLSConfig: &tls.Config{},
+		})
+		require.ErrorContains(t, err, "tls: first record does not look like a TLS handshake")
+		require.NotNil(t, client)
+	})
+}
+
+func TestClient__AllowedIPs(t *testing.T) {
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname:   "127.0.0.1:2121",
+		Username:   "admin",
+		Password:   "123456",
+		AllowedIPs: []string{"127.0.0.0/8"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, client.Ping())
+
+	// Data connections are checked as well
+	filenames, err := client.ListFiles("/")
+	require.NoError(t, err)
+	require.NotEmpty(t, filenames)
+	require.NoError(t, client.Close())
+
+	client, err = go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname:   "127.0.0.1:2121",
+		Username:   "admin",
+		Password:   "123456",
+		AllowedIPs: []string{"10.0.0.0/8", "192.168.1.1"},
+	})
+	require.Nil(t, client)
+
+	var ipErr *go_ftp.IPNotAllowedError
+	require.ErrorAs(t, err, &ipErr)
+	require.Equal(t, "127.0.0.1", ipErr.IP.String())
+}
+
+func TestClient__Stat(t *testing.T) {
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	info, err := client.Stat("first.txt")
+	require.NoError(t, err)
+	require.Equal(t, "first.txt", info.Name())
+	require.Equal(t, int64(size(t, filepath.Join("testdata", "ftp-server", "first.txt"))), info.Size())
+	require.False(t, info.IsDir())
+	require.True(t, info.Mode().IsRegular())
+	require.False(t, info.ModTime().IsZero())
+
+	info, err = client.Stat("/archive")
+	require.NoError(t, err)
+	require.Equal(t, "archive", info.Name())
+	require.True(t, info.IsDir())
+
+	_, err = client.Stat("/archive/missing.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	t.Run("open", func(t *testing.T) {
+		file, err := client.Open("/archive/old.txt")
+		require.NoError(t, err)
+		defer file.Close()
+
+		stat, err := file.Stat()
+		require.NoError(t, err)
+		require.Equal(t, "old.txt", stat.Name())
+		require.Equal(t, stat.ModTime(), file.ModTime)
+	})
+
+	t.Run("walk", func(t *testing.T) {
+		err := client.Walk("/archive", func(path string, d fs.DirEntry, err error) error {
+			require.NoError(t, err)
+
+			info, err := d.Info()
+			require.NoError(t, err)
+			require.Equal(t, d.Name(), info.Name())
+			require.Equal(t, d.IsDir(), info.IsDir())
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestClient__Rename(t *testing.T) {
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	upload := func(path, contents string) {
+		t.Helper()
+		require.NoError(t, client.UploadFile(path, io.NopCloser(strings.NewReader(contents))))
+	}
+
+	upload("/rename-me.txt", "first")
+	require.NoError(t, client.Rename("/rename-me.txt", "/renamed.txt"))
+	t.Cleanup(func() { client.Delete("/renamed.txt") })
+
+	_, err = client.Stat("/rename-me.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	// The target exists
+	upload("/rename-me.txt", "second")
+	t.Cleanup(func() { client.Delete("/rename-me.txt") })
+
+	err = client.Rename("/rename-me.txt", "/renamed.txt")
+	require.ErrorIs(t, err, fs.ErrExist)
+
+	require.NoError(t, client.Rename("/rename-me.txt", "/renamed.txt", go_ftp.OverwriteExisting()))
+
+	file, err := client.Open("/renamed.txt")
+	require.NoError(t, err)
+	bs, _ := io.ReadAll(file)
+	require.Equal(t, "second", string(bs))
+	require.NoError(t, file.Close())
+
+	// Move into another directory
+	require.NoError(t, client.Move("/renamed.txt", "/archive/renamed.txt"))
+	t.Cleanup(func() { client.Delete("/archive/renamed.txt") })
+
+	info, err := client.Stat("/archive/renamed.txt")
+	require.NoError(t, err)
+	require.Equal(t, int64(len("second")), info.Size())
+
+	err = client.Rename("/missing.txt", "/still-missing.txt")
+	require.Error(t, err)
+
+	// Existing files are kept when the source is missing
+	err = client.Rename("/missing.txt", "/archive/renamed.txt", go_ftp.OverwriteExisting())
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = client.Stat("/archive/renamed.txt")
+	require.NoError(t, err)
+}
+
+func TestClient__Logger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname:      "127.0.0.1:2121",
+		Username:      "admin",
+		Password:      "123456",
+		Logger:        logger,
+		LogTranscript: true,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.NoError(t, client.UploadFile("/archive/logged.txt", io.NopCloser(strings.NewReader("logged"))))
+	t.Cleanup(func() { client.Delete("/archive/logged.txt") })
+
+	file, err := client.Open("/archive/logged.txt")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	logs := buf.String()
+	require.Contains(t, logs, `msg="ftp connect" host=127.0.0.1:2121`)
+	require.Contains(t, logs, `msg="ftp login" host=127.0.0.1:2121 username=admin`)
+	require.Contains(t, logs, `msg="ftp change directory" host=127.0.0.1:2121 path=/archive/`)
+	require.Contains(t, logs, `msg="ftp upload" host=127.0.0.1:2121 path=logged.txt offset=0 bytes=6`)
+	require.Contains(t, logs, `msg="ftp download" host=127.0.0.1:2121 path=logged.txt offset=0 bytes=6`)
+	require.Contains(t, logs, `msg="ftp command" host=127.0.0.1:2121 line="PASS REDACTED"`)
+	require.Contains(t, logs, `msg="ftp command" host=127.0.0.1:2121 line="STOR logged.txt"`)
+	require.NotContains(t, logs, "123456")
+
+	// Opening a file doesn't list its directory
+	require.NotRegexp(t, `line="?(LIST|MLSD|NLST)`, logs)
+}
+
+type recordedMetrics struct {
+	go_ftp.NopMetrics
+
+	mu         sync.Mutex
+	operations []string
+	bytes      map[string]int64
+	connects   int
+}
+
+func (m *recordedMetrics) ObserveOperation(host, op string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.operations = append(m.operations, fmt.Sprintf("%s %s %v", host, op, err != nil))
+}
+
+func (m *recordedMetrics) AddBytes(host, direction string, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytes[direction] += n
+}
+
+func (m *recordedMetrics) ObserveConnect(host string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connects++
+}
+
+func TestClient__Metrics(t *testing.T) {
+	metrics := &recordedMetrics{bytes: make(map[string]int64)}
+
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+		Metrics:  metrics,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.NoError(t, client.UploadFile("/metered.txt", io.NopCloser(strings.NewReader("metered"))))
+	t.Cleanup(func() { client.Delete("/metered.txt") })
+
+	file, err := client.Reader("/metered.txt")
+	require.NoError(t, err)
+	_, err = io.ReadAll(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	w, err := client.Create("/metered.txt")
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "rewritten")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	_, err = client.Stat("/missing.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	require.Equal(t, []string{
+		"127.0.0.1:2121 upload false",
+		"127.0.0.1:2121 reader false",
+		"127.0.0.1:2121 openfile false",
+		"127.0.0.1:2121 stat true",
+	}, metrics.operations)
+	require.Equal(t, map[string]int64{"upload": 16, "download": 7}, metrics.bytes)
+	require.Equal(t, 1, metrics.connects)
+}
+
+func TestClient__Progress(t *testing.T) {
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Progress is reported from another goroutine, but never concurrently
+	var reports []go_ftp.Progress
+	record := func(p go_ftp.Progress) { reports = append(reports, p) }
+
+	contents := strings.Repeat("progress", 1024)
+	path := filepath.Join(t.TempDir(), "progress.txt")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	fd, err := os.Open(path)
+	require.NoError(t, err)
+	defer fd.Close()
+
+	err = client.UploadFile("/progress.txt", fd, go_ftp.UploadProgress(time.Millisecond, record))
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Delete("/progress.txt") })
+
+	final := reports[len(reports)-1]
+	require.True(t, final.Done)
+	require.NoError(t, final.Err)
+	require.Equal(t, "/progress.txt", final.Path)
+	require.Equal(t, int64(len(contents)), final.Bytes)
+	require.Equal(t, int64(len(contents)), final.Total)
+
+	largerFileSize := size(t, filepath.Join("testdata", "ftp-server", "bigdata", "large.txt"))
+
+	t.Run("Reader", func(t *testing.T) {
+		reports = nil
+
+		file, err := client.Reader("/bigdata/large.txt", go_ftp.DownloadProgress(time.Millisecond, record))
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, file)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		final := reports[len(reports)-1]
+		require.True(t, final.Done)
+		require.Equal(t, int64(largerFileSize), final.Bytes)
+		require.Equal(t, int64(largerFileSize), final.Total)
+		require.Zero(t, final.Remaining)
+	})
+
+	t.Run("Open", func(t *testing.T) {
+		reports = nil
+
+		file, err := client.Open("/bigdata/large.txt", go_ftp.DownloadProgress(time.Millisecond, record))
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		// Open reads the file before returning
+		final := reports[len(reports)-1]
+		require.True(t, final.Done)
+		require.Equal(t, int64(largerFileSize), final.Bytes)
+	})
+}
+
+func TestClient__RateLimit(t *testing.T) {
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+		RateLimit: go_ftp.RateLimit{
+			BytesPerSecond: 64 * 1024,
+			Burst:          16 * 1024,
+		},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// After the burst the remaining 32KiB take half a second
+	contents := strings.Repeat("a", 48*1024)
+	start := time.Now()
+	require.NoError(t, client.UploadFile("/throttled.txt", io.NopCloser(strings.NewReader(contents))))
+	require.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	t.Cleanup(func() { client.Delete("/throttled.txt") })
+
+	start = time.Now()
+	file, err := client.Open("/throttled.txt")
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	require.NoError(t, file.Close())
+
+	// Limits can be lifted for a transfer
+	start = time.Now()
+	file, err = client.Reader("/throttled.txt", go_ftp.DownloadRateLimit(go_ftp.RateLimit{}))
+	require.NoError(t, err)
+	bs, err := io.ReadAll(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	require.Equal(t, contents, string(bs))
+	require.Less(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestClient__Watch(t *testing.T) {
+	client, err := go_ftp.NewClientContext(context.Background(), go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := go_ftp.Watch(ctx, client, "/archive", go_ftp.WatchOptions{
+		Interval: 20 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	var paths []string
+	for range 2 {
+		event := <-events
+		require.NoError(t, event.Err)
+		require.Equal(t, go_ftp.FileCreated, event.Op)
+		paths = append(paths, event.Path)
+	}
+	require.Equal(t, []string{"/archive/empty2.txt", "/archive/old.txt"}, paths)
+
+	require.NoError(t, client.UploadFile("/archive/watched.txt", io.NopCloser(strings.NewReader("watched"))))
+	event := <-events
+	require.Equal(t, go_ftp.FileCreated, event.Op)
+	require.Equal(t, "/archive/watched.txt", event.Path)
+	require.Equal(t, int64(7), event.File.Size)
+
+	require.NoError(t, client.Delete("/archive/watched.txt"))
+	event = <-events
+	require.Equal(t, go_ftp.FileRemoved, event.Op)
+	require.Equal(t, "/archive/watched.txt", event.Path)
+}
+
+func TestClient__PathErrors(t *testing.T) {
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Open("/missing.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	var pathErr *go_ftp.PathError
+	require.ErrorAs(t, err, &pathErr)
+	require.Equal(t, "open", pathErr.Op)
+	require.Equal(t, "/missing.txt", pathErr.Path)
+	require.GreaterOrEqual(t, pathErr.Code, 550) // 550 or 551, depending on the server
+
+	_, err = client.Reader("/missing.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	err = client.Rename("/missing.txt", "/still-missing.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	err = client.RemoveDir("/missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, client.Delete("/missing.txt"))
+	err = client.Delete("/archive")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = client.ReadDir("/missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	err = client.UploadFile("/missing/a.txt", io.NopCloser(strings.NewReader("a")))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.ErrorAs(t, err, &pathErr)
+	require.Equal(t, "upload", pathErr.Op)
+
+	// Deleting a missing file isn't an error
+	require.NoError(t, client.Delete("/missing.txt"))
+}
+
+func TestClient__Directories(t *testing.T) {
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	t.Cleanup(func() { client.RemoveAll("/mkdir-test") })
+
+	err = client.UploadFile("/mkdir-test/a/b/c.txt", io.NopCloser(strings.NewReader("c")))
+	require.ErrorContains(t, err, "change dir for upload")
+
+	err = client.UploadFile("/mkdir-test/a/b/c.txt", io.NopCloser(strings.NewReader("c")), go_ftp.CreateParentDirs())
+	require.NoError(t, err)
+
+	require.NoError(t, client.Mkdir("/mkdir-test/empty"))
+	require.Error(t, client.Mkdir("/mkdir-test/x/y"))
+	require.NoError(t, client.MkdirAll("/mkdir-test/x/y"))
+	require.NoError(t, client.MkdirAll("/mkdir-test/x/y"))
+
+	info, err := client.Stat("/mkdir-test/x/y")
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+
+	require.NoError(t, client.RemoveDir("/mkdir-test/empty"))
+	require.Error(t, client.RemoveDir("/mkdir-test/a"))
+
+	require.NoError(t, client.RemoveAll("/mkdir-test"))
+	require.NoError(t, client.RemoveAll("/mkdir-test"))
+
+	_, err = client.Stat("/mkdir-test")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	// The connection is still in the directory we started in
+	file, err := client.Open("first.txt")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
+
+func TestClient__AtomicUpload(t *testing.T) {
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	t.Cleanup(func() { client.RemoveAll("/atomic") })
+	require.NoError(t, client.Mkdir("/atomic"))
+
+	read := func(path string) string {
+		t.Helper()
+		file, err := client.Open(path)
+		require.NoError(t, err)
+		bs, _ := io.ReadAll(file)
+		require.NoError(t, file.Close())
+		return string(bs)
+	}
+
+	err = client.UploadFile("/atomic/a.txt", io.NopCloser(strings.NewReader("first")), go_ftp.AtomicUpload(go_ftp.AtomicUploadConfig{}))
+	require.NoError(t, err)
+	require.Equal(t, "first", read("/atomic/a.txt"))
+
+	// Replace the file through a temporary directory
+	cfg := go_ftp.AtomicUploadConfig{Dir: ".uploading", Suffix: ".part"}
+	err = client.UploadFile("/atomic/a.txt", io.NopCloser(strings.NewReader("second")), go_ftp.AtomicUpload(cfg))
+	require.NoError(t, err)
+	require.Equal(t, "second", read("/atomic/a.txt"))
+
+	// Failed uploads leave the existing file alone
+	contents := io.NopCloser(io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("bad read"))))
+	err = client.UploadFile("/atomic/a.txt", contents, go_ftp.AtomicUpload(go_ftp.AtomicUploadConfig{}))
+	require.ErrorContains(t, err, "bad read")
+	require.Equal(t, "second", read("/atomic/a.txt"))
+
+	var names []string
+	err = client.Walk("/atomic", func(path string, d fs.DirEntry, err error) error {
+		if !d.IsDir() {
+			names = append(names, d.Name())
+		}
+		return err
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.txt"}, names)
+}
+
+func TestClient__AtomicUploadWithoutMDTM(t *testing.T) {
+	// Servers without MLST or MDTM are only asked for SIZE, as the hidden temporary file is
+	// left out of listings
+	srv := ftptest.NewServer(t, "SIZE")
+
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: srv.Addr,
+		Username: ftptest.Username,
+		Password: ftptest.Password,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	err = client.UploadFile("/a.txt", io.NopCloser(strings.NewReader("hello")), go_ftp.AtomicUpload(go_ftp.AtomicUploadConfig{}))
+	require.NoError(t, err)
+
+	bs, err := os.ReadFile(filepath.Join(srv.Root, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(bs))
+
+	_, err = os.Stat(filepath.Join(srv.Root, ".a.txt.tmp"))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestClient__AppendAndResumeUpload(t *testing.T) {
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	t.Cleanup(func() { client.RemoveAll("/append") })
+	require.NoError(t, client.Mkdir("/append"))
+
+	read := func(path string) string {
+		t.Helper()
+		file, err := client.Open(path)
+		require.NoError(t, err)
+		bs, _ := io.ReadAll(file)
+		require.NoError(t, file.Close())
+		return string(bs)
+	}
+
+	t.Run("append", func(t *testing.T) {
+		require.NoError(t, client.AppendFile("/append/a.txt", io.NopCloser(strings.NewReader("hello"))))
+		require.NoError(t, client.AppendFile("/append/a.txt", io.NopCloser(strings.NewReader(" world"))))
+		require.Equal(t, "hello world", read("/append/a.txt"))
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		source := filepath.Join(t.TempDir(), "b.txt")
+		require.NoError(t, os.WriteFile(source, []byte("hello world"), 0600))
+
+		// Start the upload with part of the file
+		require.NoError(t, client.UploadFile("/append/b.txt", io.NopCloser(strings.NewReader("hello"))))
+
+		fd, err := os.Open(source)
+		require.NoError(t, err)
+		require.NoError(t, client.UploadFile("/append/b.txt", fd, go_ftp.ResumeUpload()))
+		require.Equal(t, "hello world", read("/append/b.txt"))
+
+		// Nothing on the server yet
+		fd, err = os.Open(source)
+		require.NoError(t, err)
+		require.NoError(t, client.UploadFile("/append/c.txt", fd, go_ftp.ResumeUpload()))
+		require.Equal(t, "hello world", read("/append/c.txt"))
+
+		// The server has more than we're uploading
+		err = client.UploadFile("/append/b.txt", io.NopCloser(strings.NewReader("hello")), go_ftp.ResumeUpload())
+		require.ErrorContains(t, err, "io.Seeker")
+
+		fd, err = os.Open(filepath.Join("testdata", "ftp-server", "empty.txt"))
+		require.NoError(t, err)
+		err = client.UploadFile("/append/b.txt", fd, go_ftp.ResumeUpload())
+		require.ErrorContains(t, err, "larger than the 0 bytes to upload")
+	})
+}
+
+func TestClient__ResumeUploadWithoutMDTM(t *testing.T) {
+	srv := ftptest.NewServer(t, "SIZE")
+
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: srv.Addr,
+		Username: ftptest.Username,
+		Password: ftptest.Password,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	source := filepath.Join(t.TempDir(), "b.txt")
+	require.NoError(t, os.WriteFile(source, []byte("hello world"), 0600))
+
+	// Hidden files are resumed from their size even though listings leave them out, so only
+	// the missing bytes are sent
+	require.NoError(t, os.WriteFile(filepath.Join(srv.Root, ".b.txt"), []byte("HELLO"), 0600))
+	fd, err := os.Open(source)
+	require.NoError(t, err)
+	require.NoError(t, client.UploadFile("/.b.txt", fd, go_ftp.ResumeUpload()))
+
+	bs, err := os.ReadFile(filepath.Join(srv.Root, ".b.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "HELLO world", string(bs))
+
+	// Missing files are uploaded from the start
+	fd, err = os.Open(source)
+	require.NoError(t, err)
+	require.NoError(t, client.UploadFile("/c.txt", fd, go_ftp.ResumeUpload()))
+
+	bs, err = os.ReadFile(filepath.Join(srv.Root, "c.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(bs))
+}
+
+func TestClient__Create(t *testing.T) {
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	t.Cleanup(func() { client.RemoveAll("/create") })
+	require.NoError(t, client.Mkdir("/create"))
+
+	read := func(path string) string {
+		t.Helper()
+		file, err := client.Open(path)
+		require.NoError(t, err)
+		bs, _ := io.ReadAll(file)
+		require.NoError(t, file.Close())
+		return string(bs)
+	}
+
+	w, err := client.Create("/create/a.txt")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = fmt.Fprintf(w, "line %d\n", i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.Equal(t, "line 0\nline 1\nline 2\n", read("/create/a.txt"))
+
+	w, err = client.OpenFile("/create/a.txt", os.O_WRONLY|os.O_APPEND)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("line 3\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, "line 0\nline 1\nline 2\nline 3\n", read("/create/a.txt"))
+
+	_, err = client.OpenFile("/create/a.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_TRUNC)
+	require.ErrorIs(t, err, fs.ErrExist)
+
+	_, err = client.OpenFile("/create/missing.txt", os.O_WRONLY|os.O_TRUNC)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = client.OpenFile("/create/a.txt", os.O_RDONLY)
+	require.ErrorContains(t, err, "only os.O_WRONLY is supported")
+
+	// Upload errors are returned from Close
+	w, err = client.Create("/create/missing/b.txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("data"))
+	require.ErrorContains(t, err, "change dir for upload")
+	require.ErrorContains(t, w.Close(), "change dir for upload")
+
+	w, err = client.Create("/create/missing/b.txt", go_ftp.CreateParentDirs())
+	require.NoError(t, err)
+	_, err = io.Copy(w, strings.NewReader("data"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, "data", read("/create/missing/b.txt"))
+}
+
+func TestClient__RandomAccess(t *testing.T) {
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	t.Run("tail", func(t *testing.T) {
+		expected, err := os.ReadFile(filepath.Join("testdata", "ftp-server", "bigdata", "large.txt"))
+		require.NoError(t, err)
+
+		file, err := client.Reader("/bigdata/large.txt", go_ftp.RandomAccess(go_ftp.RandomAccessConfig{}))
+		require.NoError(t, err)
+		defer file.Close()
+
+		info, err := file.Stat()
+		require.NoError(t, err)
+		require.Equal(t, int64(len(expected)), info.Size())
+
+		tail := make([]byte, 100)
+		_, err = io.NewSectionReader(file, info.Size()-100, 100).Read(tail)
+		require.NoError(t, err)
+		require.Equal(t, expected[len(expected)-100:], tail)
+
+		_, err = file.Seek(-50, io.SeekEnd)
+		require.NoError(t, err)
+		bs, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.Equal(t, expected[len(expected)-50:], bs)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, err := zw.Create("batch.ach")
+		require.NoError(t, err)
+		w.Write([]byte("101 ..."))
+		require.NoError(t, zw.Close())
+
+		require.NoError(t, client.UploadFile("/batch.zip", io.NopCloser(&buf)))
+		t.Cleanup(func() { client.Delete("/batch.zip") })
+
+		file, err := client.Reader("/batch.zip", go_ftp.RandomAccess(go_ftp.RandomAccessConfig{BlockSize: 16}))
+		require.NoError(t, err)
+		defer file.Close()
+
+		info, err := file.Stat()
+		require.NoError(t, err)
+
+		zr, err := zip.NewReader(file, info.Size())
+		require.NoError(t, err)
+		require.Len(t, zr.File, 1)
+
+		rc, err := zr.File[0].Open()
+		require.NoError(t, err)
+		bs, _ := io.ReadAll(rc)
+		require.Equal(t, "101 ...", string(bs))
+	})
+
+	t.Run("open", func(t *testing.T) {
+		file, err := client.Open("first.txt")
+		require.NoError(t, err)
+		defer file.Close()
+
+		buf := make([]byte, 5)
+		_, err = file.ReadAt(buf, 6)
+		require.NoError(t, err)
+		require.Equal(t, "world", string(buf))
+	})
+}
+
 func size(t *testing.T, where string) int {
 	t.Helper()
 
@@ -0,0 +1,367 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/textproto"
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// serverConn is an ftp.ServerConn along with the network connections (control and data)
+// it has dialed. Tracking the network connections allows in-flight commands and transfers
+// to be aborted when a context is cancelled, which the ftp package does not support.
+type serverConn struct {
+	*ftp.ServerConn
+
+	host    string
+	logger  *slog.Logger
+	metrics Metrics
+
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	aborted bool
+}
+
+// dial connects and logs into the FTP server. ctx is only used while establishing the
+// connection, later operations need to watch their own context.
+func (cc *client) dial(ctx context.Context) (_ *serverConn, err error) {
+	sc := &serverConn{
+		host:    cc.cfg.Hostname,
+		logger:  cc.logger(),
+		metrics: cc.metrics(),
+		conns:   make(map[net.Conn]struct{}),
+	}
+
+	mode, tlsConf, err := tlsConfig(cc.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []ftp.DialOption{
+		ftp.DialWithDisabledEPSV(cc.cfg.DisableEPSV),
+		ftp.DialWithDialFunc(sc.dialFunc(ctx, cc.cfg, cc.allowedIPs, mode, tlsConf)),
+	}
+	// dialFunc starts TLS on the control connection in both modes, and the ftp package
+	// protects data connections (PBSZ / PROT).
+	if mode != TLSModeNone {
+		opts = append(opts, ftp.DialWithTLS(tlsConf))
+	}
+
+	stop := context.AfterFunc(ctx, sc.abort)
+	defer stop()
+
+	start := time.Now()
+	defer func() {
+		sc.metrics.ObserveConnect(sc.host, time.Since(start), err)
+	}()
+
+	conn, err := ftp.Dial(address(cc.cfg.Hostname, mode), opts...)
+	if err != nil {
+		var tpErr *textproto.Error
+		if mode == TLSModeExplicit && errors.As(err, &tpErr) {
+			// The server replied, but refused to upgrade the connection
+			err = fmt.Errorf("AUTH TLS: %w", err)
+		}
+		logResult(sc.logger, "ftp connect", err, slog.String("tls_mode", string(mode)))
+		return nil, err
+	}
+	logResult(sc.logger, "ftp connect", nil, slog.String("tls_mode", string(mode)), slog.Duration("duration", time.Since(start)))
+
+	loginStart := time.Now()
+	err = conn.Login(cc.cfg.Username, cc.cfg.Password)
+	logResult(sc.logger, "ftp login", err, slog.String("username", cc.cfg.Username), slog.Duration("duration", time.Since(loginStart)))
+	if err != nil {
+		conn.Quit()
+		return nil, err
+	}
+	sc.ServerConn = conn
+
+	return sc, nil
+}
+
+// dialFunc returns the function used by the ftp package to open control and data connections.
+// The ftp package leaves TLS up to the function, so connections are wrapped according to mode.
+//
+// The first connection dialed is the control connection and honors ctx. Data connections are
+// dialed later on and are torn down with abort instead.
+func (sc *serverConn) dialFunc(ctx context.Context, cfg ClientConfig, allowedIPs []netip.Prefix, mode TLSMode, tlsConf *tls.Config) func(network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout: cmp.Or(cfg.Timeout, ftp.DefaultDialTimeout),
+		Control: allowedIPsControl(allowedIPs, cfg.Hostname),
+	}
+	control := true
+
+	return func(network, address string) (net.Conn, error) {
+		dialCtx := context.Background()
+		isControl := control
+		if control {
+			dialCtx = ctx
+			control = false
+		}
+
+		conn, err := dialer.DialContext(dialCtx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		conn, err = sc.track(conn)
+		if err != nil {
+			return nil, err
+		}
+		if isControl {
+			return sc.controlConn(conn, mode, tlsConf, cfg.LogTranscript)
+		}
+		if mode != TLSModeNone {
+			return tls.Client(conn, tlsConf), nil
+		}
+		return conn, nil
+	}
+}
+
+// controlConn starts TLS on the control connection according to mode, which for explicit TLS
+// happens after AUTH TLS, and logs its commands and replies when logTranscript is set. The
+// transcript is taken here rather than from the ftp package's debug output, which includes
+// the listings read from data connections.
+func (sc *serverConn) controlConn(conn net.Conn, mode TLSMode, tlsConf *tls.Config, logTranscript bool) (net.Conn, error) {
+	logged := func(conn net.Conn) net.Conn {
+		if logTranscript {
+			return &transcriptConn{Conn: conn, logger: sc.logger}
+		}
+		return conn
+	}
+
+	switch mode {
+	case TLSModeImplicit:
+		return logged(tls.Client(conn, tlsConf)), nil
+	case TLSModeExplicit:
+		greeting, err := authTLS(logged(conn))
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return &greetedConn{Conn: logged(tls.Client(conn, tlsConf)), greeting: greeting}, nil
+	}
+	return logged(conn), nil
+}
+
+// authTLS reads the server's greeting and asks it to start TLS with AUTH TLS. The greeting is
+// returned for the ftp package to read once TLS has started.
+func authTLS(conn net.Conn) ([]byte, error) {
+	var greeting bytes.Buffer
+	r := textproto.NewReader(bufio.NewReader(io.TeeReader(conn, &greeting)))
+	if _, _, err := r.ReadResponse(ftp.StatusReady); err != nil {
+		return nil, err
+	}
+	replay := bytes.Clone(greeting.Bytes())
+
+	if _, err := io.WriteString(conn, "AUTH TLS\r\n"); err != nil {
+		return nil, err
+	}
+	if _, _, err := r.ReadResponse(ftp.StatusAuthOK); err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+// greetedConn returns the greeting read by authTLS before reading from Conn.
+type greetedConn struct {
+	net.Conn
+
+	greeting []byte
+}
+
+func (c *greetedConn) Read(p []byte) (int, error) {
+	if len(c.greeting) > 0 {
+		n := copy(p, c.greeting)
+		c.greeting = c.greeting[n:]
+		return n, nil
+	}
+	return c.Conn.Read(p)
+}
+
+var errAborted = errors.New("connection aborted")
+
+func (sc *serverConn) track(conn net.Conn) (net.Conn, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.aborted {
+		conn.Close()
+		return nil, fmt.Errorf("dial %s: %w", conn.RemoteAddr(), errAborted)
+	}
+
+	tc := &trackedConn{Conn: conn, sc: sc}
+	sc.conns[tc] = struct{}{}
+	return tc, nil
+}
+
+// abort closes every network connection of sc, which unblocks any goroutine reading or
+// writing on them. sc is unusable afterwards.
+func (sc *serverConn) abort() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.aborted = true
+	for conn := range sc.conns {
+		conn.(*trackedConn).Conn.Close()
+	}
+	clear(sc.conns)
+}
+
+func (sc *serverConn) isAborted() bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	return sc.aborted
+}
+
+// watch aborts sc once ctx is done. The returned function stops watching ctx.
+func (sc *serverConn) watch(ctx context.Context) (stop func() bool) {
+	return context.AfterFunc(ctx, sc.abort)
+}
+
+type trackedConn struct {
+	net.Conn
+
+	sc *serverConn
+}
+
+func (c *trackedConn) Close() error {
+	c.sc.mu.Lock()
+	delete(c.sc.conns, c)
+	c.sc.mu.Unlock()
+
+	return c.Conn.Close()
+}
+
+// ChangeDir changes the working directory, which is logged at debug level.
+func (sc *serverConn) ChangeDir(path string) error {
+	err := sc.ServerConn.ChangeDir(path)
+	if err != nil {
+		sc.logger.Warn("ftp change directory failed", slog.String("path", path), slog.Any("error", err))
+	} else {
+		sc.logger.Debug("ftp change directory", slog.String("path", path))
+	}
+	return err
+}
+
+func (sc *serverConn) Retr(path string) (io.ReadCloser, error) {
+	return sc.RetrFrom(path, 0)
+}
+
+// RetrFrom starts downloading path from offset. The download is logged once the response is
+// closed.
+func (sc *serverConn) RetrFrom(path string, offset uint64) (io.ReadCloser, error) {
+	start := time.Now()
+	resp, err := sc.ServerConn.RetrFrom(path, offset)
+	if err != nil {
+		logResult(sc.logger, "ftp download", err, slog.String("path", path), slog.Uint64("offset", offset))
+		return nil, err
+	}
+	return &retrResponse{Response: resp, sc: sc, path: path, offset: offset, start: start}, nil
+}
+
+// retrResponse counts the bytes downloaded and logs the download once it's closed.
+type retrResponse struct {
+	*ftp.Response
+
+	sc     *serverConn
+	path   string
+	offset uint64
+	start  time.Time
+
+	n       int64
+	readErr error
+}
+
+func (r *retrResponse) Read(p []byte) (int, error) {
+	n, err := r.Response.Read(p)
+	r.n += int64(n)
+	r.sc.metrics.AddBytes(r.sc.host, "download", int64(n))
+	if err != nil && err != io.EOF {
+		r.readErr = err
+	}
+	return n, err
+}
+
+func (r *retrResponse) Close() error {
+	err := r.Response.Close()
+	logResult(r.sc.logger, "ftp download", errors.Join(r.readErr, err),
+		slog.String("path", r.path),
+		slog.Uint64("offset", r.offset),
+		slog.Int64("bytes", r.n),
+		slog.Duration("duration", time.Since(r.start)),
+	)
+	return err
+}
+
+func (sc *serverConn) Stor(path string, r io.Reader) error {
+	return sc.store("ftp upload", path, r, 0, sc.ServerConn.Stor)
+}
+
+func (sc *serverConn) StorFrom(path string, r io.Reader, offset uint64) error {
+	return sc.store("ftp upload", path, r, offset, func(path string, r io.Reader) error {
+		return sc.ServerConn.StorFrom(path, r, offset)
+	})
+}
+
+func (sc *serverConn) Append(path string, r io.Reader) error {
+	return sc.store("ftp append", path, r, 0, sc.ServerConn.Append)
+}
+
+func (sc *serverConn) store(op, path string, r io.Reader, offset uint64, store func(string, io.Reader) error) error {
+	start := time.Now()
+	counter := &countingReader{Reader: r, observe: func(n int) {
+		sc.metrics.AddBytes(sc.host, "upload", int64(n))
+	}}
+	err := store(path, counter)
+	logResult(sc.logger, op, err,
+		slog.String("path", path),
+		slog.Uint64("offset", offset),
+		slog.Int64("bytes", counter.n),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return err
+}
+
+// contextError annotates err with the cause of ctx being done. Operations which are aborted
+// fail with network errors, so this lets callers check errors.Is(err, context.Canceled).
+func contextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	cause := context.Cause(ctx)
+	if errors.Is(err, cause) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", cause, err)
+}
+
+// contextReader fails reads once ctx is done, even when data has already
+// been buffered from an aborted connection.
+type contextReader struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.ReadCloser.Read(p)
+}
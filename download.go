@@ -0,0 +1,101 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ReadOption configures Open and Reader.
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	resumeRetries int
+	randomAccess  *RandomAccessConfig
+	progress      *progressOptions
+	rateLimit     *RateLimit
+}
+
+// ResumeDownload reconnects and continues downloading from the last byte read when reading
+// the file fails, up to retries times. Reconnecting waits for the backoff of
+// ClientConfig.Retry between attempts. The server must support the REST command.
+func ResumeDownload(retries int) ReadOption {
+	return func(o *readOptions) {
+		o.resumeRetries = retries
+	}
+}
+
+func newReadOptions(opts []ReadOption) readOptions {
+	var o readOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// resumingReader reads from rc and reopens the download at the current offset when
+// reading fails, which happens when the connection drops.
+type resumingReader struct {
+	ctx  context.Context
+	open func(offset int64) (io.ReadCloser, error)
+
+	// backoff is the delay before each attempt to reopen the download, when it's set.
+	backoff func(attempt int) time.Duration
+
+	rc      io.ReadCloser
+	offset  int64
+	retries int
+	failed  error
+}
+
+func (r *resumingReader) Read(p []byte) (int, error) {
+	for {
+		if r.failed != nil {
+			if err := r.resume(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := r.rc.Read(p)
+		r.offset += int64(n)
+		if err == nil || err == io.EOF || r.retries <= 0 || r.ctx.Err() != nil {
+			return n, err
+		}
+
+		// Hand back what was read before reconnecting
+		r.failed = err
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (r *resumingReader) resume() error {
+	// Release the broken connection first as the pool could be limited to one connection
+	r.rc.Close()
+
+	for attempt := 0; r.retries > 0 && r.ctx.Err() == nil; attempt++ {
+		if r.backoff != nil && sleep(r.ctx, r.backoff(attempt)) != nil {
+			break
+		}
+		r.retries--
+
+		rc, err := r.open(r.offset)
+		if err == nil {
+			r.rc = rc
+			r.failed = nil
+			return nil
+		}
+		r.failed = err
+	}
+	return fmt.Errorf("resuming download at byte %d: %w", r.offset, r.failed)
+}
+
+func (r *resumingReader) Close() error {
+	return r.rc.Close()
+}
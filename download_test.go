@@ -0,0 +1,147 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumingReader(t *testing.T) {
+	const contents = "hello world, this is a longer file"
+
+	// Each download fails after reading a few bytes
+	var offsets []int64
+	open := func(offset int64) (io.ReadCloser, error) {
+		offsets = append(offsets, offset)
+		end := min(offset+10, int64(len(contents)))
+		r := io.MultiReader(strings.NewReader(contents[offset:end]), iotest.ErrReader(errors.New("connection reset")))
+		if end == int64(len(contents)) {
+			r = strings.NewReader(contents[offset:])
+		}
+		return io.NopCloser(r), nil
+	}
+	first, _ := open(0)
+
+	r := &resumingReader{
+		ctx:     context.Background(),
+		open:    open,
+		rc:      first,
+		retries: 5,
+	}
+	bs, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, contents, string(bs))
+	require.Equal(t, []int64{0, 10, 20, 30}, offsets)
+	require.Equal(t, 2, r.retries)
+
+	// The retry budget runs out
+	offsets = nil
+	first, _ = open(0)
+	r = &resumingReader{
+		ctx:     context.Background(),
+		open:    open,
+		rc:      first,
+		retries: 1,
+	}
+	bs, err = io.ReadAll(r)
+	require.ErrorContains(t, err, "connection reset")
+	require.Equal(t, contents[:20], string(bs))
+
+	// Reconnecting fails, with a backoff before each attempt
+	var backoffs []int
+	first, _ = open(0)
+	r = &resumingReader{
+		ctx: context.Background(),
+		open: func(offset int64) (io.ReadCloser, error) {
+			return nil, errors.New("connection refused")
+		},
+		backoff: func(attempt int) time.Duration {
+			backoffs = append(backoffs, attempt)
+			return time.Millisecond
+		},
+		rc:      first,
+		retries: 3,
+	}
+	_, err = io.ReadAll(r)
+	require.ErrorContains(t, err, "resuming download at byte 10: connection refused")
+	require.Equal(t, []int{0, 1, 2}, backoffs)
+
+	// Cancelling the context stops waiting to reconnect
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	first, _ = open(0)
+	r = &resumingReader{
+		ctx:     ctx,
+		open:    open,
+		backoff: func(attempt int) time.Duration { return time.Hour },
+		rc:      first,
+		retries: 3,
+	}
+	r.failed = errors.New("connection reset")
+	require.ErrorContains(t, r.resume(), "connection reset")
+}
+
+func TestClient_ResumeDownload(t *testing.T) {
+	cc, err := NewClient(ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { cc.Close() })
+
+	// Keep track of connections so we can break them
+	p := cc.(*client).pool
+	var mu sync.Mutex
+	var conns []*serverConn
+	dial := p.dial
+	p.dial = func(ctx context.Context) (*serverConn, error) {
+		conn, err := dial(ctx)
+		if err == nil {
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+		return conn, err
+	}
+	require.NoError(t, p.close())
+
+	expected, err := os.ReadFile(filepath.Join("testdata", "ftp-server", "bigdata", "large.txt"))
+	require.NoError(t, err)
+
+	file, err := cc.Reader("/bigdata/large.txt", ResumeDownload(2))
+	require.NoError(t, err)
+	defer file.Close()
+
+	var buf bytes.Buffer
+	_, err = io.CopyN(&buf, file, 1024*1024)
+	require.NoError(t, err)
+
+	mu.Lock()
+	require.Len(t, conns, 1)
+	conns[0].abort()
+	mu.Unlock()
+
+	_, err = io.Copy(&buf, file)
+	require.NoError(t, err)
+	require.Equal(t, len(expected), buf.Len())
+	require.True(t, bytes.Equal(expected, buf.Bytes()))
+
+	mu.Lock()
+	require.Len(t, conns, 2)
+	mu.Unlock()
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"errors"
+	"io/fs"
+	"net/textproto"
+	"strings"
+)
+
+// PathError records an error along with the operation and path which caused it. Code is the
+// FTP reply code when the server rejected a command, or zero otherwise.
+//
+// Replies are matched by errors.Is against fs.ErrNotExist, fs.ErrExist and fs.ErrPermission.
+// Servers use 550 for most failures, so its message is used to tell them apart and 550 replies
+// which don't say why they failed match none of them. 530 and 532 (not logged in) match
+// fs.ErrPermission.
+type PathError struct {
+	Op   string
+	Path string
+	Code int
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+func (e *PathError) Is(target error) bool {
+	return isReplyError(e.Code, e.Err, target)
+}
+
+// codeError keeps the reply code of a PathError when only its underlying error is returned.
+type codeError struct {
+	code int
+	err  error
+}
+
+func (e *codeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codeError) Unwrap() error {
+	return e.err
+}
+
+func (e *codeError) Is(target error) bool {
+	return isReplyError(e.code, e.err, target)
+}
+
+func isReplyError(code int, err, target error) bool {
+	switch target {
+	case fs.ErrNotExist, fs.ErrExist, fs.ErrPermission:
+		var msg string
+		var tpErr *textproto.Error
+		if errors.As(err, &tpErr) {
+			msg = tpErr.Msg
+		}
+		return replyError(code, msg) == target
+	}
+	return false
+}
+
+// newPathError wraps err for op on path and records the reply code of a rejected command.
+func newPathError(op, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*PathError); ok {
+		return err
+	}
+
+	pathErr := &PathError{Op: op, Path: path, Err: err}
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		pathErr.Code = tpErr.Code
+	}
+	return pathErr
+}
+
+// wrapPathError is newPathError for deferred calls on a named error result.
+func wrapPathError(errp *error, op, path string) {
+	*errp = newPathError(op, path, *errp)
+}
+
+// replyError returns the fs error matching an FTP reply, or nil if there isn't one.
+func replyError(code int, msg string) error {
+	msg = strings.ToLower(msg)
+
+	switch {
+	case code == 530 || code == 532:
+		return fs.ErrPermission
+	case code != 550 && code != 551 && code != 553:
+		return nil
+	case strings.Contains(msg, "no such") || strings.Contains(msg, "not found") || strings.Contains(msg, "not exist"):
+		return fs.ErrNotExist
+	case strings.Contains(msg, "exists") || strings.Contains(msg, "already exist"):
+		return fs.ErrExist
+	case strings.Contains(msg, "permission") || strings.Contains(msg, "denied") || strings.Contains(msg, "not allowed"):
+		return fs.ErrPermission
+	case code == 553:
+		// File name not allowed
+		return fs.ErrPermission
+	}
+	// File unavailable, which could be for any reason
+	return nil
+}
+
+// notExistError is a reply which didn't say why it failed for a path which turned out to be
+// missing.
+type notExistError struct {
+	err error
+}
+
+func (e *notExistError) Error() string {
+	return e.err.Error()
+}
+
+func (e *notExistError) Unwrap() error {
+	return e.err
+}
+
+func (e *notExistError) Is(target error) bool {
+	return target == fs.ErrNotExist
+}
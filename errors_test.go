@@ -0,0 +1,60 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"errors"
+	"io/fs"
+	"net/textproto"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathError(t *testing.T) {
+	cases := []struct {
+		code     int
+		msg      string
+		expected error
+	}{
+		{550, "/missing.txt: No such file or directory", fs.ErrNotExist},
+		{550, "Can't open file: not found", fs.ErrNotExist},
+		{550, "Requested action not taken. File unavailable", nil},
+		{550, "Could not delete: is a directory", nil},
+		{550, "Directory already exists", fs.ErrExist},
+		{550, "Permission denied", fs.ErrPermission},
+		{553, "Requested action not taken. File name not allowed", fs.ErrPermission},
+		{530, "Not logged in", fs.ErrPermission},
+		{451, "Local error in processing", nil},
+	}
+	for _, tc := range cases {
+		err := newPathError("open", "/a.txt", &textproto.Error{Code: tc.code, Msg: tc.msg})
+
+		var pathErr *PathError
+		require.ErrorAs(t, err, &pathErr)
+		require.Equal(t, tc.code, pathErr.Code)
+		require.Equal(t, "/a.txt", pathErr.Path)
+
+		for _, target := range []error{fs.ErrNotExist, fs.ErrExist, fs.ErrPermission} {
+			require.Equal(t, target == tc.expected, errors.Is(err, target), "%d %s is %v", tc.code, tc.msg, target)
+		}
+
+		// The FS returns the reply without the remote path, which still matches
+		if tc.expected != nil {
+			require.ErrorIs(t, unwrapPathError(err), tc.expected)
+		}
+	}
+
+	// Errors which aren't replies are wrapped as is
+	err := newPathError("stat", "/a.txt", syscall.ENOENT)
+	require.Equal(t, "stat /a.txt: no such file or directory", err.Error())
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.ErrorIs(t, err, syscall.ENOENT)
+
+	// but only once
+	require.Equal(t, err, newPathError("open", "/b.txt", err))
+	require.NoError(t, newPathError("open", "/a.txt", nil))
+}
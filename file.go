@@ -5,6 +5,8 @@
 package go_ftp
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"time"
@@ -59,6 +61,32 @@ func (f *File) Read(buf []byte) (int, error) {
 	return f.Contents.Read(buf)
 }
 
+// ReadAt implements io.ReaderAt when Contents do, which is the case for files returned by Open
+// and by Reader with the RandomAccess option.
+func (f *File) ReadAt(buf []byte, off int64) (int, error) {
+	if f == nil || f.Contents == nil {
+		return 0, io.EOF
+	}
+	r, ok := f.Contents.(io.ReaderAt)
+	if !ok {
+		return 0, fmt.Errorf("ReadAt of %s: %w", f.Filename, errors.ErrUnsupported)
+	}
+	return r.ReadAt(buf, off)
+}
+
+// Seek implements io.Seeker when Contents do, which is the case for files returned by Open
+// and by Reader with the RandomAccess option.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	if f == nil || f.Contents == nil {
+		return 0, io.EOF
+	}
+	s, ok := f.Contents.(io.Seeker)
+	if !ok {
+		return 0, fmt.Errorf("Seek of %s: %w", f.Filename, errors.ErrUnsupported)
+	}
+	return s.Seek(offset, whence)
+}
+
 // Entry implements fs.DirEntry
 type Entry struct {
 	fd *ftp.Entry
@@ -74,11 +102,19 @@ func (e Entry) IsDir() bool {
 	return e.fd.Type == ftp.EntryTypeFolder
 }
 
-// Type only returns fs.ModeDir or fs.ModeSymlink
+// Type only returns fs.ModeDir or fs.ModeSymlink, or zero for regular files
 func (e Entry) Type() fs.FileMode {
-	switch e.fd.Type {
+	return entryMode(e.fd.Type)
+}
+
+func (e Entry) Info() (fs.FileInfo, error) {
+	return newFileInfo(e.fd), nil
+}
+
+func entryMode(typ ftp.EntryType) fs.FileMode {
+	switch typ {
 	case ftp.EntryTypeFile:
-		// TODO(adam):
+		return 0
 	case ftp.EntryTypeFolder:
 		return fs.ModeDir
 	case ftp.EntryTypeLink:
@@ -87,6 +123,43 @@ func (e Entry) Type() fs.FileMode {
 	return fs.ModeIrregular
 }
 
-func (e Entry) Info() (fs.FileInfo, error) {
-	return nil, nil
+// fileInfo implements fs.FileInfo for an entry on the FTP server.
+//
+// FTP servers do not report permissions, so Mode only contains the type of entry.
+type fileInfo struct {
+	entry *ftp.Entry
+}
+
+var _ fs.FileInfo = (&fileInfo{})
+
+func newFileInfo(entry *ftp.Entry) fs.FileInfo {
+	if entry == nil {
+		return nil
+	}
+	return &fileInfo{entry: entry}
+}
+
+func (fi *fileInfo) Name() string {
+	return fi.entry.Name
+}
+
+func (fi *fileInfo) Size() int64 {
+	return int64(fi.entry.Size)
+}
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	return entryMode(fi.entry.Type)
+}
+
+func (fi *fileInfo) ModTime() time.Time {
+	return fi.entry.Time
+}
+
+func (fi *fileInfo) IsDir() bool {
+	return fi.entry.Type == ftp.EntryTypeFolder
+}
+
+// Sys returns the underlying *ftp.Entry
+func (fi *fileInfo) Sys() any {
+	return fi.entry
 }
@@ -6,8 +6,11 @@ package go_ftp
 
 import (
 	"io"
+	"io/fs"
 	"testing"
+	"time"
 
+	"github.com/jlaffaye/ftp"
 	"github.com/stretchr/testify/require"
 )
 
@@ -32,3 +35,31 @@ func TestFile(t *testing.T) {
 	require.ErrorIs(t, err, io.EOF)
 	require.Equal(t, 0, n)
 }
+
+func TestEntry(t *testing.T) {
+	modTime := time.Date(2023, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+	e := Entry{
+		fd: &ftp.Entry{Name: "a.txt", Type: ftp.EntryTypeFile, Size: 42, Time: modTime},
+	}
+	require.Equal(t, fs.FileMode(0), e.Type())
+
+	info, err := e.Info()
+	require.NoError(t, err)
+	require.Equal(t, "a.txt", info.Name())
+	require.Equal(t, int64(42), info.Size())
+	require.Equal(t, modTime, info.ModTime())
+	require.True(t, info.Mode().IsRegular())
+	require.False(t, info.IsDir())
+	require.Equal(t, e.fd, info.Sys())
+
+	e = Entry{
+		fd: &ftp.Entry{Name: "dir", Type: ftp.EntryTypeFolder},
+	}
+	require.Equal(t, fs.ModeDir, e.Type())
+
+	info, err = e.Info()
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+	require.Equal(t, fs.ModeDir, info.Mode())
+}
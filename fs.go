@@ -0,0 +1,216 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+)
+
+// FS returns a read-only fs.FS of the files on the FTP server. Names are relative to the
+// directory the client logs into. The returned FS also implements fs.ReadDirFS, fs.StatFS,
+// fs.ReadFileFS, fs.GlobFS and fs.SubFS.
+//
+// Files opened from the FS are read with Client.Reader and the RandomAccess option, so they
+// implement io.ReaderAt and io.Seeker. Each uses a connection while it's being read until
+// it's closed.
+func FS(client Client) fs.FS {
+	return &ftpFS{client: client}
+}
+
+type ftpFS struct {
+	client Client
+	root   string
+}
+
+var (
+	_ fs.ReadDirFS  = (&ftpFS{})
+	_ fs.StatFS     = (&ftpFS{})
+	_ fs.ReadFileFS = (&ftpFS{})
+	_ fs.GlobFS     = (&ftpFS{})
+	_ fs.SubFS      = (&ftpFS{})
+)
+
+func (fsys *ftpFS) remotePath(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(fsys.root, name), nil
+}
+
+func (fsys *ftpFS) Open(name string) (fs.File, error) {
+	info, err := fsys.Stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: unwrapPathError(err)}
+	}
+	if info.IsDir() {
+		return &dirFile{fsys: fsys, name: name, info: info}, nil
+	}
+
+	remote, _ := fsys.remotePath("open", name)
+	file, err := fsys.client.Reader(remote, RandomAccess(RandomAccessConfig{}))
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: unwrapPathError(err)}
+	}
+	file.fileinfo = info
+	return file, nil
+}
+
+// Stat returns the entry of name from its parent directory's listing, which keeps it
+// consistent with ReadDir as servers can report times with a different precision.
+func (fsys *ftpFS) Stat(name string) (fs.FileInfo, error) {
+	remote, err := fsys.remotePath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	if name == "." {
+		info, err := fsys.client.Stat(remote)
+		if err != nil {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: unwrapPathError(err)}
+		}
+		// The root of the FS is always named "."
+		return &namedFileInfo{FileInfo: info, name: name}, nil
+	}
+
+	dir, base := path.Split(name)
+	entries, err := fsys.ReadDir(path.Clean(dir))
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: unwrapPathError(err)}
+	}
+	for _, entry := range entries {
+		if entry.Name() == base {
+			return entry.Info()
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (fsys *ftpFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	remote, err := fsys.remotePath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fsys.client.ReadDir(remote)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: unwrapPathError(err)}
+	}
+	return entries, nil
+}
+
+func (fsys *ftpFS) ReadFile(name string) ([]byte, error) {
+	remote, err := fsys.remotePath("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fsys.Stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: unwrapPathError(err)}
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errIsDir}
+	}
+
+	file, err := fsys.client.Open(remote)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: unwrapPathError(err)}
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+func (fsys *ftpFS) Glob(pattern string) ([]string, error) {
+	// Hide our Glob method as fs.Glob would call it
+	return fs.Glob(struct{ fs.ReadDirFS }{fsys}, pattern)
+}
+
+func (fsys *ftpFS) Sub(dir string) (fs.FS, error) {
+	remote, err := fsys.remotePath("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	if dir == "." {
+		return fsys, nil
+	}
+	return &ftpFS{client: fsys.client, root: remote}, nil
+}
+
+var errIsDir = errors.New("is a directory")
+
+// unwrapPathError returns the underlying error of a *PathError or *fs.PathError so errors
+// returned by the FS name the path within the FS rather than the remote path.
+func unwrapPathError(err error) error {
+	var replyErr *PathError
+	if errors.As(err, &replyErr) {
+		if replyErr.Code == 0 {
+			return replyErr.Err
+		}
+		return &codeError{code: replyErr.Code, err: replyErr.Err}
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+type namedFileInfo struct {
+	fs.FileInfo
+
+	name string
+}
+
+func (fi *namedFileInfo) Name() string {
+	return fi.name
+}
+
+// dirFile implements fs.ReadDirFile for a directory of an ftpFS.
+type dirFile struct {
+	fsys *ftpFS
+	name string
+	info fs.FileInfo
+
+	entries []fs.DirEntry
+	read    bool
+	offset  int
+}
+
+var _ fs.ReadDirFile = (&dirFile{})
+
+func (d *dirFile) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dirFile) Read(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errIsDir}
+}
+
+func (d *dirFile) Close() error {
+	return nil
+}
+
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(remaining))
+	d.offset += n
+	return remaining[:n], nil
+}
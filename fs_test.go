@@ -0,0 +1,58 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp_test
+
+import (
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	go_ftp "github.com/moov-io/go-ftp"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFS(t *testing.T) {
+	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	fsys := go_ftp.FS(client)
+	require.NoError(t, fstest.TestFS(fsys, "first.txt", "archive/old.txt", "with-empty/data.txt"))
+
+	bs, err := fs.ReadFile(fsys, "first.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello world", strings.TrimSpace(string(bs)))
+
+	matches, err := fs.Glob(fsys, "archive/*.txt")
+	require.NoError(t, err)
+	require.Equal(t, []string{"archive/empty2.txt", "archive/old.txt"}, matches)
+
+	_, err = fs.Stat(fsys, "missing.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = fsys.Open("../first.txt")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+
+	sub, err := fs.Sub(fsys, "archive")
+	require.NoError(t, err)
+	require.NoError(t, fstest.TestFS(sub, "old.txt"))
+}
+
+func TestFS_MockClient(t *testing.T) {
+	client := go_ftp.NewMockClient(t)
+
+	require.NoError(t, client.UploadFile("a.txt", io.NopCloser(strings.NewReader("a"))))
+	require.NoError(t, client.UploadFile("dir/b.txt", io.NopCloser(strings.NewReader("b")), go_ftp.CreateParentDirs()))
+
+	fsys := go_ftp.FS(client)
+	require.NoError(t, fstest.TestFS(fsys, "a.txt", "dir/b.txt"))
+}
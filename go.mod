@@ -8,6 +8,8 @@ require (
 	github.com/jlaffaye/ftp v0.2.0
 	github.com/stretchr/testify v1.11.1
 	golang.org/x/sync v0.20.0
+	golang.org/x/time v0.15.0
+	software.sslmate.com/src/go-pkcs12 v0.5.0
 )
 
 require (
@@ -15,5 +17,6 @@ require (
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/crypto v0.54.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
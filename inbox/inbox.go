@@ -0,0 +1,347 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package inbox processes the files uploaded into a directory on an FTP server, moving each
+// into an archive or error directory once it's handled.
+//
+//	processor := inbox.NewProcessor(client, inbox.Config{
+//		Inbound: "/inbound",
+//		Ledger:  inbox.NewFileLedger("inbound-ledger.json"),
+//	}, func(ctx context.Context, file *go_ftp.File) error {
+//		...
+//	})
+//	err := processor.Run(ctx, time.Minute)
+package inbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	go_ftp "github.com/moov-io/go-ftp"
+)
+
+// Handler processes a downloaded file. The file is closed once Handler returns. Files are
+// archived when it returns nil, and moved to the error directory otherwise, unless the error
+// is from Retry. Files whose Handler fails because ctx is done are left in place, and handled
+// again by the next Process without counting as an attempt.
+type Handler func(ctx context.Context, file *go_ftp.File) error
+
+// Config configures a Processor.
+type Config struct {
+	// Inbound is the directory to process files from. Subdirectories are skipped.
+	Inbound string
+
+	// Archive and Error are where files are moved once they're handled successfully or not,
+	// which default to "archive" and "error" inside Inbound. They're created when missing.
+	Archive string
+	Error   string
+
+	// Retry is where files are moved when Handler fails with an error from Retry. Files in
+	// Retry are processed along with Inbound, up to MaxAttempts times in total (three by
+	// default) before they're moved to Error. Without a Retry directory these files are
+	// moved to Error.
+	Retry       string
+	MaxAttempts int
+
+	// Concurrency is how many files are processed at once, which defaults to one. Each file
+	// uses one of the client's connections while it's downloaded.
+	Concurrency int
+
+	// Ledger records which files were handled, so a file is never handed to Handler twice
+	// even when the process stops before moving it. The default keeps records in memory.
+	Ledger Ledger
+
+	// ReadOptions are used to download files, e.g. to resume interrupted downloads.
+	ReadOptions []go_ftp.ReadOption
+
+	// Logger records the outcome of each file processed by Run. Nothing is logged when nil.
+	Logger *slog.Logger
+}
+
+// Processor hands each file of an inbound directory to a Handler and moves it out of the
+// directory afterwards.
+type Processor struct {
+	client  go_ftp.ClientContext
+	cfg     Config
+	handler Handler
+}
+
+// NewProcessor returns a Processor of the files in cfg.Inbound.
+func NewProcessor(client go_ftp.ClientContext, cfg Config, handler Handler) *Processor {
+	if cfg.Archive == "" {
+		cfg.Archive = path.Join(cfg.Inbound, "archive")
+	}
+	if cfg.Error == "" {
+		cfg.Error = path.Join(cfg.Inbound, "error")
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.Ledger == nil {
+		cfg.Ledger = NewMemoryLedger()
+	}
+	return &Processor{client: client, cfg: cfg, handler: handler}
+}
+
+// Result describes what happened to a file.
+type Result struct {
+	// Path is where the file was processed from and Dest where it was moved to, which is
+	// empty when the file was left in place.
+	Path string
+	Dest string
+
+	// Err is the error from Handler, or from downloading or moving the file.
+	Err error
+}
+
+var (
+	// ErrInterrupted is the Result.Err of files which were handed to Handler by a process
+	// which stopped before recording the outcome. They're moved to the error directory rather
+	// than handled again.
+	ErrInterrupted = errors.New("inbox: processing was interrupted")
+
+	errRetry = errors.New("retry")
+)
+
+// Retry marks err as temporary, so the file is moved to the retry directory and handled again
+// by a later Process.
+func Retry(err error) error {
+	return &retryError{err: err}
+}
+
+type retryError struct {
+	err error
+}
+
+func (e *retryError) Error() string { return e.err.Error() }
+func (e *retryError) Unwrap() error { return e.err }
+func (e *retryError) Is(target error) bool {
+	return target == errRetry
+}
+
+// Run calls Process every interval until ctx is done, which it returns the cause of. Files
+// left in place by a failed Process are tried again on the next one.
+func (p *Processor) Run(ctx context.Context, interval time.Duration) error {
+	logger := p.cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		results, err := p.Process(ctx)
+		for _, result := range results {
+			attrs := []any{slog.String("path", result.Path), slog.String("dest", result.Dest)}
+			if result.Err != nil {
+				logger.Warn("inbox file failed", append(attrs, slog.Any("error", result.Err))...)
+			} else {
+				logger.Info("inbox file processed", attrs...)
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			logger.Warn("inbox process failed", slog.Any("error", err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
+}
+
+// Process handles the files currently in the inbound and retry directories. The returned
+// error is from listing the directories or from files which couldn't be downloaded or moved,
+// which are left for the next Process. Failures of Handler are only in the Results.
+func (p *Processor) Process(ctx context.Context) ([]Result, error) {
+	var files []inboundFile
+	for _, dir := range []string{p.cfg.Inbound, p.cfg.Retry} {
+		if dir == "" {
+			continue
+		}
+		found, err := p.list(ctx, dir)
+		if err != nil {
+			return nil, fmt.Errorf("inbox: listing %s: %w", dir, err)
+		}
+		files = append(files, found...)
+	}
+
+	results := make([]Result, len(files))
+	errs := make([]error, len(files))
+	sem := make(chan struct{}, max(p.cfg.Concurrency, 1))
+
+	var wg sync.WaitGroup
+	for i, file := range files {
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+
+			results[i], errs[i] = p.process(ctx, file)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("inbox: %s: %w", file.path, errs[i])
+			}
+		})
+	}
+	wg.Wait()
+
+	return results, errors.Join(errs...)
+}
+
+type inboundFile struct {
+	path string
+	key  string // identifies this upload of the file in the Ledger
+}
+
+func (p *Processor) list(ctx context.Context, dir string) ([]inboundFile, error) {
+	entries, err := p.client.ReadDirContext(ctx, dir)
+	if errors.Is(err, fs.ErrNotExist) && dir == p.cfg.Retry {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []inboundFile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		// The directory isn't part of the key, so it follows files moved to the retry directory
+		key := fmt.Sprintf("%s|%d|%s", entry.Name(), info.Size(), info.ModTime().UTC().Format(time.RFC3339))
+		files = append(files, inboundFile{path: path.Join(dir, entry.Name()), key: key})
+	}
+	return files, nil
+}
+
+// process hands file to the handler, unless the Ledger has an outcome for it already, and
+// moves it to where it belongs. The returned error is set when the file was left in place
+// for the next Process.
+func (p *Processor) process(ctx context.Context, file inboundFile) (Result, error) {
+	result := Result{Path: file.path}
+	fail := func(err error) (Result, error) {
+		result.Err = errors.Join(result.Err, err)
+		return result, err
+	}
+
+	entry, err := p.cfg.Ledger.Get(ctx, file.key)
+	if err != nil {
+		return fail(err)
+	}
+
+	switch entry.State {
+	case StateSucceeded:
+		return p.finish(ctx, file, result, p.cfg.Archive)
+	case StateFailed:
+		return p.finish(ctx, file, result, p.cfg.Error)
+	case StateStarted:
+		result.Err = ErrInterrupted
+		return p.finish(ctx, file, result, p.cfg.Error)
+	}
+
+	// Download before recording the attempt, so files which can't be read are left in place
+	remote, err := p.client.ReaderContext(ctx, file.path, p.cfg.ReadOptions...)
+	if err != nil {
+		return fail(err)
+	}
+
+	previous := entry
+	entry = Entry{State: StateStarted, Attempts: entry.Attempts + 1}
+	if err := p.cfg.Ledger.Set(ctx, file.key, entry); err != nil {
+		remote.Close()
+		return fail(err)
+	}
+
+	result.Err = p.handler(ctx, remote)
+	remote.Close()
+
+	if err := ctx.Err(); err != nil && errors.Is(result.Err, err) {
+		// Processing is shutting down rather than failing, so forget the attempt
+		ctx := context.WithoutCancel(ctx)
+		if previous == (Entry{}) {
+			err = errors.Join(err, p.cfg.Ledger.Delete(ctx, file.key))
+		} else {
+			err = errors.Join(err, p.cfg.Ledger.Set(ctx, file.key, previous))
+		}
+		return result, err
+	}
+
+	switch {
+	case result.Err == nil:
+		entry.State = StateSucceeded
+	case errors.Is(result.Err, errRetry) && p.cfg.Retry != "" && entry.Attempts < p.cfg.MaxAttempts:
+		entry.State = StateRetry
+	default:
+		entry.State = StateFailed
+	}
+	if err := p.cfg.Ledger.Set(ctx, file.key, entry); err != nil {
+		// The next Process finds the file as started and moves it to the error directory
+		return fail(err)
+	}
+
+	switch entry.State {
+	case StateSucceeded:
+		return p.finish(ctx, file, result, p.cfg.Archive)
+	case StateRetry:
+		if path.Dir(file.path) == path.Clean(p.cfg.Retry) {
+			return result, nil // already waiting in the retry directory
+		}
+		return p.move(ctx, file, result, p.cfg.Retry)
+	}
+	return p.finish(ctx, file, result, p.cfg.Error)
+}
+
+// finish moves file into dir and forgets it, as it won't be seen again.
+func (p *Processor) finish(ctx context.Context, file inboundFile, result Result, dir string) (Result, error) {
+	result, err := p.move(ctx, file, result, dir)
+	if err != nil {
+		return result, err
+	}
+	if err := p.cfg.Ledger.Delete(ctx, file.key); err != nil {
+		// The file is gone, so a stale record is harmless
+		result.Err = errors.Join(result.Err, err)
+	}
+	return result, nil
+}
+
+// move renames file into dir, adding a timestamp to its name when dir has a file by that
+// name already.
+func (p *Processor) move(ctx context.Context, file inboundFile, result Result, dir string) (Result, error) {
+	name := path.Base(file.path)
+	ext := path.Ext(name)
+	now := time.Now().UTC()
+
+	candidates := []string{
+		name,
+		fmt.Sprintf("%s.%s%s", strings.TrimSuffix(name, ext), now.Format("20060102-150405"), ext),
+		fmt.Sprintf("%s.%s%s", strings.TrimSuffix(name, ext), now.Format("20060102-150405.000000000"), ext),
+	}
+	var err error
+	for _, candidate := range candidates {
+		dest := path.Join(dir, candidate)
+		err = p.client.MoveContext(ctx, file.path, dest)
+		if err == nil {
+			result.Dest = dest
+			return result, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			break
+		}
+	}
+	err = fmt.Errorf("moving to %s: %w", dir, err)
+	result.Err = errors.Join(result.Err, err)
+	return result, err
+}
@@ -0,0 +1,262 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package inbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	go_ftp "github.com/moov-io/go-ftp"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor(t *testing.T) {
+	ctx := context.Background()
+	client := go_ftp.NewMockClient(t)
+	upload(t, client, "/inbound/a.csv", "a")
+	upload(t, client, "/inbound/b.csv", "b")
+	upload(t, client, "/inbound/bad.csv", "bad")
+	upload(t, client, "/inbound/archive/b.csv", "yesterday's b")
+
+	var handled atomic.Int32
+	processor := NewProcessor(client, Config{Inbound: "/inbound", Concurrency: 3}, func(ctx context.Context, file *go_ftp.File) error {
+		handled.Add(1)
+		bs, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		if string(bs) == "bad" {
+			return errors.New("invalid file")
+		}
+		return nil
+	})
+
+	results, err := processor.Process(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.Equal(t, int32(3), handled.Load())
+
+	require.Equal(t, Result{Path: "/inbound/a.csv", Dest: "/inbound/archive/a.csv"}, results[0])
+	require.Regexp(t, `^/inbound/archive/b\.\d{8}-\d{6}\.csv$`, results[1].Dest) // b.csv was taken
+	require.NoError(t, results[1].Err)
+	require.Equal(t, "/inbound/error/bad.csv", results[2].Dest)
+	require.EqualError(t, results[2].Err, "invalid file")
+
+	require.Equal(t, "a", read(t, client, "/inbound/archive/a.csv"))
+	require.Equal(t, "bad", read(t, client, "/inbound/error/bad.csv"))
+
+	// Everything was moved out of the inbound directory
+	results, err = processor.Process(ctx)
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func TestProcessor_Ledger(t *testing.T) {
+	ctx := context.Background()
+	client := go_ftp.NewMockClient(t)
+	upload(t, client, "/inbound/interrupted.csv", "a")
+	upload(t, client, "/inbound/unmoved.csv", "b")
+
+	ledger := NewFileLedger(filepath.Join(t.TempDir(), "ledger.json"))
+	processor := NewProcessor(client, Config{Inbound: "/inbound", Ledger: ledger}, func(ctx context.Context, file *go_ftp.File) error {
+		t.Errorf("%s was handled again", file.Filename)
+		return nil
+	})
+
+	// A previous process stopped while handling one file and before moving the other
+	files, err := processor.list(ctx, "/inbound")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.NoError(t, ledger.Set(ctx, files[0].key, Entry{State: StateStarted, Attempts: 1}))
+	require.NoError(t, ledger.Set(ctx, files[1].key, Entry{State: StateSucceeded, Attempts: 1}))
+
+	results, err := processor.Process(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Result{
+		{Path: "/inbound/interrupted.csv", Dest: "/inbound/error/interrupted.csv", Err: ErrInterrupted},
+		{Path: "/inbound/unmoved.csv", Dest: "/inbound/archive/unmoved.csv"},
+	}, results)
+
+	// Moved files are forgotten
+	for _, file := range files {
+		entry, err := ledger.Get(ctx, file.key)
+		require.NoError(t, err)
+		require.Empty(t, entry)
+	}
+}
+
+func TestProcessor_Retry(t *testing.T) {
+	ctx := context.Background()
+	client := go_ftp.NewMockClient(t)
+	upload(t, client, "/inbound/a.csv", "a")
+
+	var handled int
+	processor := NewProcessor(client, Config{
+		Inbound:     "/inbound",
+		Retry:       "/retry",
+		MaxAttempts: 2,
+	}, func(ctx context.Context, file *go_ftp.File) error {
+		handled++
+		return Retry(errors.New("database unavailable"))
+	})
+
+	results, err := processor.Process(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "/retry/a.csv", results[0].Dest)
+	require.EqualError(t, results[0].Err, "database unavailable")
+
+	// The last attempt moves the file to the error directory
+	results, err = processor.Process(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "/inbound/error/a.csv", results[0].Dest)
+	require.Equal(t, 2, handled)
+
+	results, err = processor.Process(ctx)
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func TestProcessor_Errors(t *testing.T) {
+	ctx := context.Background()
+	client := go_ftp.NewMockClient(t)
+	upload(t, client, "/inbound/a.csv", "a")
+
+	var handled bool
+	processor := NewProcessor(client, Config{Inbound: "/inbound"}, func(ctx context.Context, file *go_ftp.File) error {
+		handled = true
+		return nil
+	})
+
+	// Files which can't be downloaded are left for the next Process
+	client.ReaderErr = errors.New("425 can't open data connection")
+	results, err := processor.Process(ctx)
+	require.ErrorContains(t, err, "inbox: /inbound/a.csv: 425 can't open data connection")
+	require.Empty(t, results[0].Dest)
+	require.False(t, handled)
+
+	client.ReaderErr = nil
+	client.ReadDirErr = errors.New("421 too many connections")
+	_, err = processor.Process(ctx)
+	require.ErrorContains(t, err, "inbox: listing /inbound: 421 too many connections")
+
+	client.ReadDirErr = nil
+	results, err = processor.Process(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "/inbound/archive/a.csv", results[0].Dest)
+	require.True(t, handled)
+}
+
+func TestProcessor_Canceled(t *testing.T) {
+	client := go_ftp.NewMockClient(t)
+	upload(t, client, "/inbound/a.csv", "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var handled int
+	processor := NewProcessor(client, Config{Inbound: "/inbound"}, func(ctx context.Context, file *go_ftp.File) error {
+		handled++
+		if handled == 1 {
+			cancel() // shut down while the file is handled
+			return ctx.Err()
+		}
+		return nil
+	})
+
+	results, err := processor.Process(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, []Result{{Path: "/inbound/a.csv", Err: context.Canceled}}, results)
+
+	// The file is handled again, rather than being moved to the error directory
+	results, err = processor.Process(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Result{{Path: "/inbound/a.csv", Dest: "/inbound/archive/a.csv"}}, results)
+	require.Equal(t, 2, handled)
+}
+
+func TestFileLedger(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ledger.json")
+
+	ledger := NewFileLedger(path)
+	entry, err := ledger.Get(ctx, "a.csv")
+	require.NoError(t, err)
+	require.Empty(t, entry)
+
+	require.NoError(t, ledger.Set(ctx, "a.csv", Entry{State: StateRetry, Attempts: 1}))
+	require.NoError(t, ledger.Set(ctx, "b.csv", Entry{State: StateStarted, Attempts: 1}))
+	require.NoError(t, ledger.Delete(ctx, "b.csv"))
+
+	// Records survive restarts
+	ledger = NewFileLedger(path)
+	entry, err = ledger.Get(ctx, "a.csv")
+	require.NoError(t, err)
+	require.Equal(t, Entry{State: StateRetry, Attempts: 1}, entry)
+
+	entry, err = ledger.Get(ctx, "b.csv")
+	require.NoError(t, err)
+	require.Empty(t, entry)
+}
+
+func TestProcessor_Server(t *testing.T) {
+	ctx := context.Background()
+	client, err := go_ftp.NewClientContext(ctx, go_ftp.ClientConfig{
+		Hostname:       "127.0.0.1:2121",
+		Username:       "admin",
+		Password:       "123456",
+		MaxConnections: 2,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	t.Cleanup(func() { client.RemoveAll("/inbox") })
+	upload(t, client, "/inbox/inbound/a.csv", "a")
+	upload(t, client, "/inbox/inbound/b.csv", "b")
+
+	var contents []string
+	processor := NewProcessor(client, Config{
+		Inbound: "/inbox/inbound",
+		Archive: "/inbox/archive",
+	}, func(ctx context.Context, file *go_ftp.File) error {
+		bs, err := io.ReadAll(file)
+		contents = append(contents, string(bs))
+		return err
+	})
+
+	results, err := processor.Process(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Result{
+		{Path: "/inbox/inbound/a.csv", Dest: "/inbox/archive/a.csv"},
+		{Path: "/inbox/inbound/b.csv", Dest: "/inbox/archive/b.csv"},
+	}, results)
+	require.Equal(t, []string{"a", "b"}, contents)
+
+	files, err := client.ListFiles("/inbox/inbound")
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func upload(t *testing.T, client go_ftp.Client, path, contents string) {
+	t.Helper()
+
+	err := client.UploadFile(path, io.NopCloser(strings.NewReader(contents)), go_ftp.CreateParentDirs())
+	require.NoError(t, err)
+}
+
+func read(t *testing.T, client go_ftp.Client, path string) string {
+	t.Helper()
+
+	file, err := client.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	bs, err := io.ReadAll(file)
+	require.NoError(t, err)
+	return string(bs)
+}
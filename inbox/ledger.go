@@ -0,0 +1,131 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/moov-io/go-ftp/internal/jsonfile"
+)
+
+// State is how far a file got through processing.
+type State string
+
+const (
+	StateStarted   State = "started"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateRetry     State = "retry"
+)
+
+// Entry is the bookkeeping of a file.
+type Entry struct {
+	State State `json:"state"`
+
+	// Attempts is how many times the file was handed to Handler.
+	Attempts int `json:"attempts"`
+}
+
+// Ledger records the processing of files by a key of their name, size and modification time.
+// Records are deleted once files are moved out of the inbound and retry directories.
+// Implementations must be safe for concurrent use.
+type Ledger interface {
+	// Get returns the entry of key, which is empty when there isn't one.
+	Get(ctx context.Context, key string) (Entry, error)
+
+	Set(ctx context.Context, key string, entry Entry) error
+	Delete(ctx context.Context, key string) error
+}
+
+// NewMemoryLedger returns a Ledger which keeps records in memory.
+func NewMemoryLedger() Ledger {
+	return &memoryLedger{entries: make(map[string]Entry)}
+}
+
+type memoryLedger struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+func (l *memoryLedger) Get(ctx context.Context, key string) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.entries[key], nil
+}
+
+func (l *memoryLedger) Set(ctx context.Context, key string, entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[key] = entry
+	return nil
+}
+
+func (l *memoryLedger) Delete(ctx context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, key)
+	return nil
+}
+
+// NewFileLedger returns a Ledger which keeps records in a JSON file at path, which is created
+// when it doesn't exist. The file is replaced on every change, so processing survives restarts.
+// It must not be shared between processes.
+func NewFileLedger(path string) Ledger {
+	return &fileLedger{path: path}
+}
+
+type fileLedger struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (l *fileLedger) Get(ctx context.Context, key string) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries, err := l.read()
+	if err != nil {
+		return Entry{}, err
+	}
+	return entries[key], nil
+}
+
+func (l *fileLedger) Set(ctx context.Context, key string, entry Entry) error {
+	return l.update(func(entries map[string]Entry) {
+		entries[key] = entry
+	})
+}
+
+func (l *fileLedger) Delete(ctx context.Context, key string) error {
+	return l.update(func(entries map[string]Entry) {
+		delete(entries, key)
+	})
+}
+
+func (l *fileLedger) update(fn func(entries map[string]Entry)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries, err := l.read()
+	if err != nil {
+		return err
+	}
+	fn(entries)
+
+	return jsonfile.Write(l.path, entries)
+}
+
+func (l *fileLedger) read() (map[string]Entry, error) {
+	entries := make(map[string]Entry)
+	if err := jsonfile.Read(l.path, &entries); err != nil {
+		return nil, fmt.Errorf("reading ledger: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,382 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package ftptest
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Username and Password are the credentials a Server accepts.
+const (
+	Username = "admin"
+	Password = "123456"
+)
+
+// Server is a minimal FTP server serving files from a temporary directory. It imitates servers
+// the fake server in docker-compose.yml can't, such as ones without MDTM or MLST, and like many
+// servers it leaves hidden files out of LIST.
+type Server struct {
+	// Addr is the host:port clients connect to.
+	Addr string
+
+	// Root is the local directory served as /.
+	Root string
+
+	features  []string
+	tlsConfig *tls.Config
+}
+
+// NewServer starts a Server which is stopped when the test finishes. SIZE, MDTM and MLST are
+// only supported when they're included in features.
+func NewServer(t *testing.T, features ...string) *Server {
+	t.Helper()
+	return NewTLSServer(t, nil, features...)
+}
+
+// NewTLSServer starts a Server which supports explicit TLS (AUTH TLS) with conf.
+func NewTLSServer(t *testing.T, conf *tls.Config, features ...string) *Server {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	srv := &Server{
+		Addr:      ln.Addr().String(),
+		Root:      t.TempDir(),
+		features:  features,
+		tlsConfig: conf,
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv
+}
+
+type session struct {
+	srv  *Server
+	w    *bufio.Writer
+	cwd  string
+	user string
+	pasv net.Listener
+	prot bool
+	rest int64
+	rnfr string
+}
+
+func (s *session) reply(code int, msg string) {
+	fmt.Fprintf(s.w, "%d %s\r\n", code, msg)
+	s.w.Flush()
+}
+
+func (s *session) abs(p string) string {
+	if !path.IsAbs(p) {
+		p = path.Join(s.cwd, p)
+	}
+	return path.Clean(p)
+}
+
+func (s *session) local(p string) string {
+	return filepath.Join(s.srv.Root, filepath.FromSlash(s.abs(p)))
+}
+
+func (srv *Server) supports(feature string) bool {
+	return slices.Contains(srv.features, feature)
+}
+
+func (srv *Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	s := &session{srv: srv, w: bufio.NewWriter(conn), cwd: "/"}
+	defer func() {
+		if s.pasv != nil {
+			s.pasv.Close()
+		}
+	}()
+	s.reply(220, "ftptest ready")
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		cmd = strings.ToUpper(cmd)
+
+		switch cmd {
+		case "AUTH":
+			if srv.tlsConfig == nil {
+				s.reply(502, "Command not implemented")
+				continue
+			}
+			s.reply(234, "Starting TLS")
+			tlsConn := tls.Server(conn, srv.tlsConfig)
+			s.w, r = bufio.NewWriter(tlsConn), bufio.NewReader(tlsConn)
+		case "PBSZ":
+			s.reply(200, "OK")
+		case "PROT":
+			s.prot = arg == "P"
+			s.reply(200, "OK")
+		case "USER":
+			s.user = arg
+			s.reply(331, "User name ok, password required")
+		case "PASS":
+			if s.user != Username || arg != Password {
+				s.reply(530, "Incorrect password, not logged in")
+				continue
+			}
+			s.reply(230, "Password ok, continue")
+		case "FEAT":
+			fmt.Fprint(s.w, "211-Features:\r\n EPSV\r\n REST STREAM\r\n")
+			for _, feature := range srv.features {
+				fmt.Fprintf(s.w, " %s\r\n", feature)
+			}
+			s.reply(211, "End")
+		case "TYPE", "OPTS", "NOOP":
+			s.reply(200, "OK")
+		case "QUIT":
+			s.reply(221, "Goodbye")
+			return
+		case "PWD":
+			s.reply(257, fmt.Sprintf("%q is the current directory", s.cwd))
+		case "CWD":
+			if info, err := os.Stat(s.local(arg)); err != nil || !info.IsDir() {
+				s.reply(550, "No such directory")
+				continue
+			}
+			s.cwd = s.abs(arg)
+			s.reply(250, "Directory changed to "+s.cwd)
+		case "EPSV", "PASV":
+			s.passive(cmd)
+		case "REST":
+			s.rest, _ = strconv.ParseInt(arg, 10, 64)
+			s.reply(350, "Restarting at "+arg)
+		case "SIZE", "MDTM", "MLST":
+			s.describe(cmd, arg)
+		case "LIST", "NLST":
+			s.list(cmd, arg)
+		case "RETR":
+			s.retr(arg)
+		case "STOR", "APPE":
+			s.stor(cmd, arg)
+		case "DELE", "RMD":
+			if err := os.Remove(s.local(arg)); err != nil {
+				s.reply(550, "No such file or directory")
+				continue
+			}
+			s.reply(250, "Removed")
+		case "MKD":
+			if err := os.Mkdir(s.local(arg), 0755); err != nil {
+				s.reply(550, "Could not create directory")
+				continue
+			}
+			s.reply(257, fmt.Sprintf("%q created", s.abs(arg)))
+		case "RNFR":
+			if _, err := os.Stat(s.local(arg)); err != nil {
+				s.reply(550, "No such file or directory")
+				continue
+			}
+			s.rnfr = s.local(arg)
+			s.reply(350, "Ready for RNTO")
+		case "RNTO":
+			if err := os.Rename(s.rnfr, s.local(arg)); err != nil {
+				s.reply(550, "Could not rename")
+				continue
+			}
+			s.reply(250, "Renamed")
+		default:
+			s.reply(502, "Command not implemented")
+		}
+	}
+}
+
+func (s *session) passive(cmd string) {
+	if s.pasv != nil {
+		s.pasv.Close()
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		s.reply(425, err.Error())
+		return
+	}
+	s.pasv = ln
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	if cmd == "EPSV" {
+		s.reply(229, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", port))
+	} else {
+		s.reply(227, fmt.Sprintf("Entering Passive Mode (127,0,0,1,%d,%d)", port/256, port%256))
+	}
+}
+
+// data accepts the data connection of the last EPSV or PASV command.
+func (s *session) data() (net.Conn, bool) {
+	if s.pasv == nil {
+		s.reply(425, "Use EPSV or PASV first")
+		return nil, false
+	}
+	defer func() {
+		s.pasv.Close()
+		s.pasv = nil
+	}()
+
+	s.pasv.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	conn, err := s.pasv.Accept()
+	if err != nil {
+		s.reply(425, "Can't open data connection")
+		return nil, false
+	}
+	s.reply(150, "Opening data connection")
+	if s.prot {
+		return tls.Server(conn, s.srv.tlsConfig), true
+	}
+	return conn, true
+}
+
+func (s *session) describe(cmd, arg string) {
+	if !s.srv.supports(cmd) {
+		s.reply(502, "Command not implemented")
+		return
+	}
+	info, err := os.Stat(s.local(arg))
+	if err != nil {
+		s.reply(550, "No such file or directory")
+		return
+	}
+
+	switch cmd {
+	case "SIZE":
+		if info.IsDir() {
+			s.reply(550, "Not a regular file")
+			return
+		}
+		s.reply(213, strconv.FormatInt(info.Size(), 10))
+	case "MDTM":
+		s.reply(213, info.ModTime().UTC().Format("20060102150405"))
+	case "MLST":
+		typ := "file"
+		if info.IsDir() {
+			typ = "dir"
+		}
+		fmt.Fprintf(s.w, "250-File details\r\n type=%s;size=%d;modify=%s; %s\r\n", typ, info.Size(), info.ModTime().UTC().Format("20060102150405"), s.abs(arg))
+		s.reply(250, "End")
+	}
+}
+
+func (s *session) list(cmd, arg string) {
+	entries, err := os.ReadDir(s.local(strings.TrimSpace(strings.TrimPrefix(arg, "-a"))))
+	if err != nil {
+		s.reply(550, "No such directory")
+		return
+	}
+	conn, ok := s.data()
+	if !ok {
+		return
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if cmd == "NLST" {
+			fmt.Fprintf(conn, "%s\r\n", entry.Name())
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		mode := "-rw-r--r--"
+		if info.IsDir() {
+			mode = "drwxr-xr-x"
+		}
+		fmt.Fprintf(conn, "%s 1 ftp ftp %12d %s %s\r\n", mode, info.Size(), info.ModTime().Format("Jan _2 15:04"), entry.Name())
+	}
+	conn.Close()
+	s.reply(226, "Transfer complete")
+}
+
+func (s *session) retr(arg string) {
+	offset := s.rest
+	s.rest = 0
+
+	fd, err := os.Open(s.local(arg))
+	if err != nil {
+		s.reply(550, "No such file or directory")
+		return
+	}
+	defer fd.Close()
+	if _, err := fd.Seek(offset, io.SeekStart); err != nil {
+		s.reply(550, "Invalid restart offset")
+		return
+	}
+
+	conn, ok := s.data()
+	if !ok {
+		return
+	}
+	_, err = io.Copy(conn, fd)
+	conn.Close()
+	if err != nil {
+		s.reply(426, "Transfer aborted")
+		return
+	}
+	s.reply(226, "Transfer complete")
+}
+
+func (s *session) stor(cmd, arg string) {
+	offset := s.rest
+	s.rest = 0
+
+	flag := os.O_CREATE | os.O_WRONLY
+	switch {
+	case cmd == "APPE":
+		flag |= os.O_APPEND
+	case offset == 0:
+		flag |= os.O_TRUNC
+	}
+	fd, err := os.OpenFile(s.local(arg), flag, 0644)
+	if err != nil {
+		s.reply(553, "Could not create file")
+		return
+	}
+	defer fd.Close()
+	if offset > 0 {
+		fd.Truncate(offset)
+		fd.Seek(offset, io.SeekStart)
+	}
+
+	conn, ok := s.data()
+	if !ok {
+		return
+	}
+	_, err = io.Copy(fd, conn)
+	conn.Close()
+	if err != nil {
+		s.reply(426, "Transfer aborted")
+		return
+	}
+	s.reply(226, "Transfer complete")
+}
@@ -0,0 +1,37 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package ftptest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	srv := NewServer(t, "SIZE")
+	require.NoError(t, os.WriteFile(filepath.Join(srv.Root, "a.txt"), []byte("hello"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(srv.Root, ".hidden"), []byte("secret"), 0600))
+
+	conn, err := ftp.Dial(srv.Addr)
+	require.NoError(t, err)
+	defer conn.Quit()
+	require.NoError(t, conn.Login(Username, Password))
+
+	size, err := conn.FileSize(".hidden")
+	require.NoError(t, err)
+	require.Equal(t, int64(6), size)
+
+	require.False(t, conn.IsGetTimeSupported())
+	_, err = conn.GetEntry("a.txt")
+	require.Error(t, err)
+
+	names, err := conn.NameList("/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.txt"}, names)
+}
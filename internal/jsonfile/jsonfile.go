@@ -0,0 +1,81 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package jsonfile keeps values in JSON files which survive crashes.
+package jsonfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Read decodes the file at path into v, which is left as is when the file doesn't exist.
+func Read(path string, v any) error {
+	bs, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(bs, v); err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	return nil
+}
+
+// Write replaces the file at path with v encoded as JSON. It's written to a temporary file
+// which is synced to disk before being renamed over path, and the directory is synced after
+// the rename, so a crash leaves either the old or the new file and never one which is
+// half-written.
+func Write(path string, v any) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bs); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes the entries of dir to disk so a rename into it survives power failures.
+// Windows can't sync directories, its renames are flushed with the file system journal.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	fd, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := fd.Sync(); err != nil {
+		fd.Close()
+		return fmt.Errorf("syncing %s: %w", dir, err)
+	}
+	return fd.Close()
+}
@@ -0,0 +1,40 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	values := map[string]int{"kept": 1}
+	require.NoError(t, Read(path, &values))
+	require.Equal(t, map[string]int{"kept": 1}, values)
+
+	require.NoError(t, Write(path, map[string]int{"a": 1, "b": 2}))
+	require.NoError(t, Read(path, &values))
+	require.Equal(t, map[string]int{"kept": 1, "a": 1, "b": 2}, values)
+
+	// Temporary files are cleaned up
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0600))
+	require.ErrorContains(t, Read(path, &values), "reading "+path)
+}
+
+func TestSyncDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, syncDir(dir))
+	require.Error(t, syncDir(filepath.Join(dir, "missing")))
+}
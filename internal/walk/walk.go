@@ -0,0 +1,49 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package walk lists the files of a remote directory tree.
+package walk
+
+import (
+	"context"
+	"io/fs"
+	"path"
+)
+
+// DirReader reads the entries of a remote directory, which go_ftp.ClientContext implements.
+type DirReader interface {
+	ReadDirContext(ctx context.Context, dir string) ([]fs.DirEntry, error)
+}
+
+// Files calls fn for each regular file in dir and its subdirectories with its path relative
+// to dir. ReadDir is used rather than Walk as the paths Walk returns depend on how dir is
+// written.
+func Files(ctx context.Context, client DirReader, dir string, fn func(name string, info fs.FileInfo) error) error {
+	return files(ctx, client, dir, "", fn)
+}
+
+func files(ctx context.Context, client DirReader, root, dir string, fn func(name string, info fs.FileInfo) error) error {
+	entries, err := client.ReadDirContext(ctx, path.Join(root, dir))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := path.Join(dir, entry.Name())
+		switch {
+		case entry.IsDir():
+			if err := files(ctx, client, root, name, fn); err != nil {
+				return err
+			}
+		case entry.Type().IsRegular():
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if err := fn(name, info); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
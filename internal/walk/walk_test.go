@@ -0,0 +1,37 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package walk_test
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	go_ftp "github.com/moov-io/go-ftp"
+	"github.com/moov-io/go-ftp/internal/walk"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiles(t *testing.T) {
+	client := go_ftp.NewMockClient(t)
+	for _, path := range []string{"/inbound/a.txt", "/inbound/sub/b.txt", "/other/c.txt"} {
+		err := client.UploadFile(path, io.NopCloser(strings.NewReader(path)), go_ftp.CreateParentDirs())
+		require.NoError(t, err)
+	}
+
+	sizes := make(map[string]int64)
+	err := walk.Files(context.Background(), client, "/inbound", func(name string, info fs.FileInfo) error {
+		sizes[name] = info.Size()
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"a.txt": 14, "sub/b.txt": 18}, sizes)
+
+	err = walk.Files(context.Background(), client, "/missing", nil)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+)
+
+// LogValue describes the config for slog with the password redacted.
+func (cfg ClientConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("hostname", cfg.Hostname),
+		slog.String("username", cfg.Username),
+		slog.String("password", redacted),
+		slog.String("tls_mode", string(cfg.TLSMode)),
+		slog.Int("max_connections", cfg.MaxConnections),
+	)
+}
+
+const redacted = "REDACTED"
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// logger returns ClientConfig.Logger with the server's hostname, or a logger which discards
+// everything when it's nil.
+func (cc *client) logger() *slog.Logger {
+	if cc.cfg.Logger == nil {
+		return discardLogger
+	}
+	return cc.cfg.Logger.With(slog.String("host", cc.cfg.Hostname))
+}
+
+// logResult logs a finished operation at info level, or at warn level with the error when it
+// failed.
+func logResult(logger *slog.Logger, msg string, err error, attrs ...any) {
+	if err != nil {
+		logger.Warn(msg+" failed", append(attrs, slog.Any("error", err))...)
+		return
+	}
+	logger.Info(msg, attrs...)
+}
+
+// transcriptConn logs the commands written to and the replies read from a control connection
+// at debug level. Passwords are redacted.
+type transcriptConn struct {
+	net.Conn
+
+	logger *slog.Logger
+
+	commands, replies lineBuffer
+}
+
+func (c *transcriptConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.replies.write(p[:n], func(line string) {
+		c.logger.Debug("ftp reply", slog.String("line", line))
+	})
+	return n, err
+}
+
+func (c *transcriptConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.commands.write(p[:n], func(line string) {
+		if strings.HasPrefix(strings.ToUpper(line), "PASS") {
+			line = "PASS " + redacted
+		}
+		c.logger.Debug("ftp command", slog.String("line", line))
+	})
+	return n, err
+}
+
+// lineBuffer collects bytes until they form complete lines.
+type lineBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+// write adds p to the buffer and calls fn with each line it completes.
+func (b *lineBuffer) write(p []byte, fn func(line string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	for {
+		idx := bytes.IndexByte(b.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		fn(strings.TrimRight(string(b.buf[:idx]), "\r"))
+		b.buf = b.buf[idx+1:]
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moov-io/go-ftp/internal/ftptest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscript(t *testing.T) {
+	for _, mode := range []TLSMode{TLSModeNone, TLSModeExplicit} {
+		t.Run(string(mode), func(t *testing.T) {
+			var tlsConf *tls.Config
+			if mode == TLSModeExplicit {
+				cert, key := generateCertificate(t)
+				tlsConf = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}}}
+			}
+			srv := ftptest.NewTLSServer(t, tlsConf, "SIZE")
+
+			// Listings are read from data connections and aren't part of the transcript
+			for _, name := range []string{"226 report.txt", "STOR x"} {
+				require.NoError(t, os.WriteFile(filepath.Join(srv.Root, name), nil, 0600))
+			}
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			cfg := ClientConfig{
+				Hostname:      srv.Addr,
+				Username:      ftptest.Username,
+				Password:      ftptest.Password,
+				TLSMode:       mode,
+				Logger:        logger,
+				LogTranscript: true,
+			}
+			if mode == TLSModeExplicit {
+				cfg.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+			}
+			client, err := NewClient(cfg)
+			require.NoError(t, err)
+			defer client.Close()
+
+			files, err := client.ListFiles("/")
+			require.NoError(t, err)
+			require.ElementsMatch(t, []string{"/226 report.txt", "/STOR x"}, files)
+
+			var lines []string
+			for _, record := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var entry struct {
+					Msg  string `json:"msg"`
+					Line string `json:"line"`
+				}
+				require.NoError(t, json.Unmarshal([]byte(record), &entry))
+				if entry.Line != "" {
+					lines = append(lines, entry.Msg+": "+entry.Line)
+				}
+			}
+
+			expected := []string{"ftp reply: 220 ftptest ready"}
+			if mode == TLSModeExplicit {
+				expected = append(expected,
+					"ftp command: AUTH TLS",
+					"ftp reply: 234 Starting TLS",
+				)
+			}
+			expected = append(expected,
+				"ftp command: USER admin",
+				"ftp reply: 331 User name ok, password required",
+				"ftp command: PASS REDACTED",
+				"ftp reply: 230 Password ok, continue",
+				"ftp command: FEAT",
+				"ftp reply: 211-Features:",
+				"ftp reply:  EPSV",
+				"ftp reply:  REST STREAM",
+				"ftp reply:  SIZE",
+				"ftp reply: 211 End",
+			)
+			require.Equal(t, expected, lines[:len(expected)])
+
+			transcript := strings.Join(lines, "\n")
+			require.Contains(t, transcript, "ftp command: LIST")
+			require.Contains(t, transcript, "ftp reply: 226 Transfer complete")
+			require.NotContains(t, transcript, "report.txt")
+			require.NotContains(t, transcript, "STOR x")
+			require.NotContains(t, transcript, ftptest.Password)
+		})
+	}
+}
+
+func TestClientConfig_LogValue(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	logger.Info("connecting", slog.Any("config", ClientConfig{
+		Hostname: "ftp.example.com:21",
+		Username: "admin",
+		Password: "123456",
+	}))
+	require.Contains(t, buf.String(), "config.hostname=ftp.example.com:21 config.username=admin config.password=REDACTED")
+	require.NotContains(t, buf.String(), "123456")
+}
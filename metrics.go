@@ -0,0 +1,61 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"time"
+)
+
+// Metrics receives measurements of a client's operations, labeled with ClientConfig.Hostname.
+// Implementations must be safe for concurrent use. Embedding NopMetrics keeps implementations
+// working when methods are added.
+//
+// The prometheus subpackage provides an implementation.
+type Metrics interface {
+	// ObserveOperation records a finished client method, such as "open", "stat" or "upload",
+	// with how long it took and the error it returned. Reader and OpenFile (including Create)
+	// are recorded as "reader" and "openfile", and finish once the transfer has started.
+	ObserveOperation(host, op string, duration time.Duration, err error)
+
+	// AddBytes counts bytes transferred in direction, which is "upload" or "download".
+	AddBytes(host, direction string, n int64)
+
+	// ObserveConnect records connecting and logging in to the server.
+	ObserveConnect(host string, duration time.Duration, err error)
+
+	// IncReconnects counts idle connections which failed a health check and were replaced.
+	IncReconnects(host string)
+}
+
+// NopMetrics discards all measurements. It's used when ClientConfig.Metrics is nil.
+type NopMetrics struct{}
+
+var _ Metrics = NopMetrics{}
+
+func (NopMetrics) ObserveOperation(host, op string, duration time.Duration, err error) {}
+func (NopMetrics) AddBytes(host, direction string, n int64)                            {}
+func (NopMetrics) ObserveConnect(host string, duration time.Duration, err error)       {}
+func (NopMetrics) IncReconnects(host string)                                           {}
+
+func (cc *client) metrics() Metrics {
+	if cc.cfg.Metrics == nil {
+		return NopMetrics{}
+	}
+	return cc.cfg.Metrics
+}
+
+// done finishes a client method on path. The error is returned as a *PathError and the
+// operation is recorded in the client's Metrics.
+func (cc *client) done(errp *error, op, path string, start time.Time) {
+	wrapPathError(errp, op, path)
+	cc.metrics().ObserveOperation(cc.cfg.Hostname, op, time.Since(start), *errp)
+}
+
+// doneOpen is done for methods which fail like Open, so their errors are a *PathError for
+// "open", but are recorded in the client's Metrics as method.
+func (cc *client) doneOpen(errp *error, method, path string, start time.Time) {
+	wrapPathError(errp, "open", path)
+	cc.metrics().ObserveOperation(cc.cfg.Hostname, method, time.Since(start), *errp)
+}
@@ -0,0 +1,346 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package mirror synchronizes a directory on an FTP server with a local directory.
+//
+//	plan, err := mirror.Download(ctx, client, "/inbound", "./inbound", mirror.Options{
+//		Include: []string{"*.ach"},
+//	})
+//	for _, action := range plan {
+//		...
+//	}
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	go_ftp "github.com/moov-io/go-ftp"
+)
+
+// Compare selects how files which exist in both directories are compared. A file is updated
+// when any of the selected comparisons finds a difference.
+type Compare int
+
+const (
+	// CompareSize updates files whose sizes differ.
+	CompareSize Compare = 1 << iota
+
+	// CompareModTime updates files whose source is newer than the destination, to the
+	// second. Downloaded files are given the modification time of the remote file, uploaded
+	// files have the time of the upload on the server. Servers without MLST can report less
+	// precise times.
+	CompareModTime
+
+	// CompareChecksum updates files with a different SHA-256 checksum. Both files are read
+	// entirely, which for remote files means downloading them.
+	CompareChecksum
+)
+
+// Options configures Download and Upload.
+type Options struct {
+	// Compare selects how existing files are compared, CompareSize|CompareModTime by default.
+	Compare Compare
+
+	// Delete removes files from the destination which are not in the source. Only files
+	// selected by Include and Exclude are removed and directories are left in place.
+	Delete bool
+
+	// DryRun returns the Plan without transferring or deleting anything.
+	DryRun bool
+
+	// Include and Exclude are path.Match patterns of the files to synchronize, matched against
+	// paths relative to the directories (e.g. "2024/*.csv"). Patterns without a slash are also
+	// matched against file names. Files are included when they match any Include pattern, or
+	// when there are none, and don't match an Exclude pattern.
+	Include []string
+	Exclude []string
+
+	// Concurrency is how many files are transferred or deleted at once, which defaults to one.
+	// Each transfer uses one of the client's connections, so ClientConfig.MaxConnections
+	// should allow for it.
+	Concurrency int
+
+	// ReadOptions are used to download files and UploadOptions to upload them, e.g. to rate
+	// limit or track the progress of transfers.
+	ReadOptions   []go_ftp.ReadOption
+	UploadOptions []go_ftp.UploadOption
+}
+
+// Op is the change an Action makes to the destination.
+type Op string
+
+const (
+	OpCreate Op = "create"
+	OpUpdate Op = "update"
+	OpDelete Op = "delete"
+)
+
+// Action is a change to one file of the destination.
+type Action struct {
+	Op Op
+
+	// Path is slash separated and relative to the directories being synchronized.
+	Path string
+
+	// Size and ModTime of the source file, which are zero for deletes.
+	Size    int64
+	ModTime time.Time
+
+	// Reason describes the difference which caused an update, such as "size differs".
+	Reason string
+
+	// Err is set when the action failed.
+	Err error
+}
+
+func (a Action) String() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%s %s", a.Op, a.Path)
+	if a.Reason != "" {
+		fmt.Fprintf(&buf, " (%s)", a.Reason)
+	}
+	if a.Err != nil {
+		fmt.Fprintf(&buf, ": %v", a.Err)
+	}
+	return buf.String()
+}
+
+// Plan is the list of actions which make the destination match the source. Transfers are
+// sorted by path, followed by deletes.
+type Plan []Action
+
+// String describes one action per line, such as "update reports/a.csv (size differs)".
+func (p Plan) String() string {
+	var buf strings.Builder
+	for _, action := range p {
+		buf.WriteString(action.String())
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// Download makes the local directory localDir match remoteDir on the server, creating
+// localDir if needed. Files are downloaded to a temporary file first so partial downloads
+// are never left in place.
+//
+// The returned Plan has the actions which were carried out, unless Options.DryRun is set.
+// Failed actions have their Err set and are joined into the returned error, other actions
+// are still carried out.
+func Download(ctx context.Context, client go_ftp.ClientContext, remoteDir, localDir string, opts Options) (Plan, error) {
+	src := &remoteTree{client: client, dir: remoteDir, readOpts: opts.ReadOptions}
+	dst := &localTree{dir: localDir}
+	return run(ctx, src, dst, opts)
+}
+
+// Upload makes remoteDir on the server match the local directory localDir. Missing remote
+// directories are created.
+//
+// The returned Plan has the actions which were carried out, unless Options.DryRun is set.
+// Failed actions have their Err set and are joined into the returned error, other actions
+// are still carried out.
+func Upload(ctx context.Context, client go_ftp.ClientContext, localDir, remoteDir string, opts Options) (Plan, error) {
+	src := &localTree{dir: localDir}
+	dst := &remoteTree{client: client, dir: remoteDir, uploadOpts: opts.UploadOptions}
+	return run(ctx, src, dst, opts)
+}
+
+// tree is one side of a synchronization. Paths are slash separated and relative to the
+// directory of the tree.
+type tree interface {
+	// list returns the regular files of the tree, which is empty when its directory doesn't
+	// exist.
+	list(ctx context.Context) (map[string]fileInfo, error)
+
+	open(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// write replaces name with contents, which it closes.
+	write(ctx context.Context, name string, contents io.ReadCloser, info fileInfo) error
+
+	remove(ctx context.Context, name string) error
+}
+
+type fileInfo struct {
+	size    int64
+	modTime time.Time
+}
+
+func run(ctx context.Context, src, dst tree, opts Options) (Plan, error) {
+	for _, pattern := range slices.Concat(opts.Include, opts.Exclude) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("mirror: pattern %q: %w", pattern, err)
+		}
+	}
+
+	plan, err := newPlan(ctx, src, dst, opts)
+	if err != nil || opts.DryRun {
+		return plan, err
+	}
+	return plan, apply(ctx, src, dst, plan, opts)
+}
+
+// newPlan compares the files of src and dst.
+func newPlan(ctx context.Context, src, dst tree, opts Options) (Plan, error) {
+	srcFiles, err := src.list(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("mirror: listing source: %w", err)
+	}
+	dstFiles, err := dst.list(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("mirror: listing destination: %w", err)
+	}
+
+	compare := opts.Compare
+	if compare == 0 {
+		compare = CompareSize | CompareModTime
+	}
+
+	var transfers, deletes Plan
+	for name, srcInfo := range srcFiles {
+		if !opts.match(name) {
+			continue
+		}
+		dstInfo, exists := dstFiles[name]
+		if !exists {
+			transfers = append(transfers, Action{Op: OpCreate, Path: name, Size: srcInfo.size, ModTime: srcInfo.modTime})
+			continue
+		}
+		reason, err := differs(ctx, src, dst, name, srcInfo, dstInfo, compare)
+		if err != nil {
+			return nil, fmt.Errorf("mirror: comparing %s: %w", name, err)
+		}
+		if reason != "" {
+			transfers = append(transfers, Action{Op: OpUpdate, Path: name, Size: srcInfo.size, ModTime: srcInfo.modTime, Reason: reason})
+		}
+	}
+	if opts.Delete {
+		for name := range dstFiles {
+			if _, exists := srcFiles[name]; !exists && opts.match(name) {
+				deletes = append(deletes, Action{Op: OpDelete, Path: name})
+			}
+		}
+	}
+
+	byPath := func(a, b Action) int { return strings.Compare(a.Path, b.Path) }
+	slices.SortFunc(transfers, byPath)
+	slices.SortFunc(deletes, byPath)
+	return append(transfers, deletes...), nil
+}
+
+// differs returns why name needs to be updated, or an empty string when it's the same.
+func differs(ctx context.Context, src, dst tree, name string, srcInfo, dstInfo fileInfo, compare Compare) (string, error) {
+	if compare&CompareSize != 0 && srcInfo.size != dstInfo.size {
+		return "size differs", nil
+	}
+	if compare&CompareModTime != 0 && srcInfo.modTime.Truncate(time.Second).After(dstInfo.modTime.Truncate(time.Second)) {
+		return "source is newer", nil
+	}
+	if compare&CompareChecksum != 0 {
+		if srcInfo.size != dstInfo.size {
+			return "checksum differs", nil
+		}
+		srcSum, err := checksum(ctx, src, name)
+		if err != nil {
+			return "", err
+		}
+		dstSum, err := checksum(ctx, dst, name)
+		if err != nil {
+			return "", err
+		}
+		if srcSum != dstSum {
+			return "checksum differs", nil
+		}
+	}
+	return "", nil
+}
+
+func checksum(ctx context.Context, t tree, name string) (string, error) {
+	r, err := t.open(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// match reports if name is selected by the Include and Exclude patterns.
+func (o Options) match(name string) bool {
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+			if !strings.Contains(pattern, "/") {
+				if ok, _ := path.Match(pattern, path.Base(name)); ok {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return (len(o.Include) == 0 || matches(o.Include)) && !matches(o.Exclude)
+}
+
+// apply carries out the actions of plan, with deletes once every transfer is finished.
+func apply(ctx context.Context, src, dst tree, plan Plan, opts Options) error {
+	split := slices.IndexFunc(plan, func(a Action) bool { return a.Op == OpDelete })
+	if split < 0 {
+		split = len(plan)
+	}
+
+	do := func(action *Action) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if action.Op == OpDelete {
+			return dst.remove(ctx, action.Path)
+		}
+		contents, err := src.open(ctx, action.Path)
+		if err != nil {
+			return err
+		}
+		return dst.write(ctx, action.Path, contents, fileInfo{size: action.Size, modTime: action.ModTime})
+	}
+	transfers := forEach(plan[:split], opts.Concurrency, do)
+	deletes := forEach(plan[split:], opts.Concurrency, do)
+	return errors.Join(transfers, deletes)
+}
+
+// forEach calls fn for every action with up to concurrency calls at once, recording the
+// errors in the actions.
+func forEach(actions []Action, concurrency int, fn func(*Action) error) error {
+	sem := make(chan struct{}, max(concurrency, 1))
+
+	var wg sync.WaitGroup
+	for i := range actions {
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+
+			actions[i].Err = fn(&actions[i])
+		})
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, action := range actions {
+		if action.Err != nil {
+			errs = append(errs, fmt.Errorf("mirror: %s %s: %w", action.Op, action.Path, action.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,237 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package mirror
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	go_ftp "github.com/moov-io/go-ftp"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownload(t *testing.T) {
+	ctx := context.Background()
+	client := go_ftp.NewMockClient(t)
+	upload(t, client, "/inbound/a.txt", "aaa")
+	upload(t, client, "/inbound/sub/b.txt", "bbbbbb")
+	upload(t, client, "/inbound/skip.log", "log")
+
+	local := t.TempDir()
+	write(t, filepath.Join(local, "sub", "b.txt"), "b")
+	write(t, filepath.Join(local, "stale.txt"), "stale")
+	write(t, filepath.Join(local, "kept.log"), "log")
+
+	opts := Options{
+		Delete:      true,
+		Exclude:     []string{"*.log"},
+		Concurrency: 4,
+	}
+
+	dryRun := opts
+	dryRun.DryRun = true
+	plan, err := Download(ctx, client, "/inbound", local, dryRun)
+	require.NoError(t, err)
+	require.Equal(t, "create a.txt\nupdate sub/b.txt (size differs)\ndelete stale.txt\n", plan.String())
+	require.FileExists(t, filepath.Join(local, "stale.txt"))
+
+	plan, err = Download(ctx, client, "/inbound", local, opts)
+	require.NoError(t, err)
+	require.Len(t, plan, 3)
+
+	require.Equal(t, "aaa", read(t, filepath.Join(local, "a.txt")))
+	require.Equal(t, "bbbbbb", read(t, filepath.Join(local, "sub", "b.txt")))
+	require.NoFileExists(t, filepath.Join(local, "stale.txt"))
+	require.NoFileExists(t, filepath.Join(local, "skip.log"))
+	require.FileExists(t, filepath.Join(local, "kept.log")) // excluded files are never deleted
+
+	// Downloads have the remote modification time, so nothing changes on the next run
+	remote, err := client.Stat("/inbound/a.txt")
+	require.NoError(t, err)
+	info, err := os.Stat(filepath.Join(local, "a.txt"))
+	require.NoError(t, err)
+	require.True(t, remote.ModTime().Equal(info.ModTime()))
+
+	plan, err = Download(ctx, client, "/inbound", local, opts)
+	require.NoError(t, err)
+	require.Empty(t, plan)
+
+	// Missing remote directories are empty
+	plan, err = Download(ctx, client, "/missing", t.TempDir(), opts)
+	require.NoError(t, err)
+	require.Empty(t, plan)
+}
+
+func TestDownload_ModTime(t *testing.T) {
+	ctx := context.Background()
+	client := go_ftp.NewMockClient(t)
+	upload(t, client, "/a.txt", "new")
+
+	local := t.TempDir()
+	write(t, filepath.Join(local, "a.txt"), "old")
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(local, "a.txt"), old, old))
+
+	plan, err := Download(ctx, client, "/", local, Options{Compare: CompareSize, DryRun: true})
+	require.NoError(t, err)
+	require.Empty(t, plan)
+
+	plan, err = Download(ctx, client, "/", local, Options{})
+	require.NoError(t, err)
+	require.Equal(t, "update a.txt (source is newer)\n", plan.String())
+	require.Equal(t, "new", read(t, filepath.Join(local, "a.txt")))
+}
+
+func TestDownload_Checksum(t *testing.T) {
+	ctx := context.Background()
+	client := go_ftp.NewMockClient(t)
+	upload(t, client, "/a.txt", "remote")
+	upload(t, client, "/b.txt", "same")
+
+	local := t.TempDir()
+	write(t, filepath.Join(local, "a.txt"), "local!")
+	write(t, filepath.Join(local, "b.txt"), "same")
+
+	plan, err := Download(ctx, client, "/", local, Options{Compare: CompareChecksum})
+	require.NoError(t, err)
+	require.Equal(t, "update a.txt (checksum differs)\n", plan.String())
+	require.Equal(t, "remote", read(t, filepath.Join(local, "a.txt")))
+}
+
+func TestUpload(t *testing.T) {
+	ctx := context.Background()
+	client := go_ftp.NewMockClient(t)
+	upload(t, client, "/outbound/old.csv", "old")
+	upload(t, client, "/outbound/notes.txt", "notes")
+
+	local := t.TempDir()
+	write(t, filepath.Join(local, "2024", "a.csv"), "a")
+	write(t, filepath.Join(local, "b.csv"), "b")
+	write(t, filepath.Join(local, "readme.txt"), "readme")
+
+	opts := Options{
+		Delete:  true,
+		Include: []string{"*.csv"},
+	}
+	plan, err := Upload(ctx, client, local, "/outbound", opts)
+	require.NoError(t, err)
+	require.Equal(t, "create 2024/a.csv\ncreate b.csv\ndelete old.csv\n", plan.String())
+
+	files, err := client.ListFiles("/outbound/2024")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/outbound/2024/a.csv"}, files)
+
+	_, err = client.Stat("/outbound/old.csv")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = client.Stat("/outbound/notes.txt")
+	require.NoError(t, err)
+
+	// Uploaded files are newer than their source
+	plan, err = Upload(ctx, client, local, "/outbound", opts)
+	require.NoError(t, err)
+	require.Empty(t, plan)
+}
+
+func TestUpload_Errors(t *testing.T) {
+	ctx := context.Background()
+	client := go_ftp.NewMockClient(t)
+
+	local := t.TempDir()
+	write(t, filepath.Join(local, "a.txt"), "a")
+	write(t, filepath.Join(local, "b.txt"), "b")
+
+	client.UploadFileErr = errors.New("552 quota exceeded")
+	plan, err := Upload(ctx, client, local, "/", Options{Concurrency: 2})
+	require.ErrorContains(t, err, "mirror: create a.txt: 552 quota exceeded")
+	require.ErrorContains(t, err, "mirror: create b.txt: 552 quota exceeded")
+	require.Len(t, plan, 2)
+	require.Equal(t, "create a.txt: 552 quota exceeded", plan[0].String())
+
+	_, err = Upload(ctx, client, local, "/", Options{Include: []string{"[a-"}})
+	require.ErrorContains(t, err, `mirror: pattern "[a-"`)
+
+	client.UploadFileErr = nil
+	client.ReadDirErr = errors.New("421 too many connections")
+	_, err = Upload(ctx, client, local, "/", Options{})
+	require.ErrorContains(t, err, "mirror: listing destination: 421 too many connections")
+}
+
+func TestOptions_match(t *testing.T) {
+	opts := Options{
+		Include: []string{"*.csv", "reports/*"},
+		Exclude: []string{"tmp-*"},
+	}
+	require.True(t, opts.match("a.csv"))
+	require.True(t, opts.match("2024/a.csv"))
+	require.True(t, opts.match("reports/summary.pdf"))
+	require.False(t, opts.match("reports/2024/summary.pdf"))
+	require.False(t, opts.match("a.txt"))
+	require.False(t, opts.match("2024/tmp-a.csv"))
+
+	require.True(t, Options{}.match("a.txt"))
+}
+
+func TestMirror_Server(t *testing.T) {
+	ctx := context.Background()
+	client, err := go_ftp.NewClientContext(ctx, go_ftp.ClientConfig{
+		Hostname:       "127.0.0.1:2121",
+		Username:       "admin",
+		Password:       "123456",
+		MaxConnections: 2,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	local := t.TempDir()
+	plan, err := Download(ctx, client, "/with-empty", local, Options{Concurrency: 2})
+	require.NoError(t, err)
+	require.Equal(t, "create EMPTY1.txt\ncreate data.txt\ncreate data2.txt\ncreate empty_file2.txt\n", plan.String())
+
+	expected, err := os.ReadFile(filepath.Join("..", "testdata", "ftp-server", "with-empty", "data.txt"))
+	require.NoError(t, err)
+	require.Equal(t, string(expected), read(t, filepath.Join(local, "data.txt")))
+
+	t.Cleanup(func() { client.RemoveAll("/mirrored") })
+	plan, err = Upload(ctx, client, local, "/mirrored", Options{Include: []string{"data*.txt"}})
+	require.NoError(t, err)
+	require.Len(t, plan, 2)
+
+	files, err := client.ListFiles("/mirrored")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"/mirrored/data.txt", "/mirrored/data2.txt"}, files)
+
+	plan, err = Upload(ctx, client, local, "/mirrored", Options{Include: []string{"data*.txt"}})
+	require.NoError(t, err)
+	require.Empty(t, plan)
+}
+
+func upload(t *testing.T, client go_ftp.Client, path, contents string) {
+	t.Helper()
+
+	err := client.UploadFile(path, io.NopCloser(strings.NewReader(contents)), go_ftp.CreateParentDirs())
+	require.NoError(t, err)
+}
+
+func write(t *testing.T, path, contents string) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0777))
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+
+	bs, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(bs)
+}
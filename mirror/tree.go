@@ -0,0 +1,142 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	go_ftp "github.com/moov-io/go-ftp"
+	"github.com/moov-io/go-ftp/internal/walk"
+)
+
+// remoteTree is a directory on the FTP server.
+type remoteTree struct {
+	client go_ftp.ClientContext
+	dir    string
+
+	readOpts   []go_ftp.ReadOption
+	uploadOpts []go_ftp.UploadOption
+}
+
+var _ tree = (&remoteTree{})
+
+func (t *remoteTree) list(ctx context.Context) (map[string]fileInfo, error) {
+	files := make(map[string]fileInfo)
+	err := walk.Files(ctx, t.client, t.dir, func(name string, info fs.FileInfo) error {
+		files[name] = fileInfo{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return files, nil
+	}
+	return files, err
+}
+
+func (t *remoteTree) open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return t.client.ReaderContext(ctx, path.Join(t.dir, name), t.readOpts...)
+}
+
+func (t *remoteTree) write(ctx context.Context, name string, contents io.ReadCloser, info fileInfo) error {
+	opts := append([]go_ftp.UploadOption{go_ftp.CreateParentDirs()}, t.uploadOpts...)
+	return t.client.UploadFileContext(ctx, path.Join(t.dir, name), contents, opts...)
+}
+
+func (t *remoteTree) remove(ctx context.Context, name string) error {
+	return t.client.DeleteContext(ctx, path.Join(t.dir, name))
+}
+
+// localTree is a directory on the local filesystem.
+type localTree struct {
+	dir string
+}
+
+var _ tree = (&localTree{})
+
+func (t *localTree) list(ctx context.Context) (map[string]fileInfo, error) {
+	files := make(map[string]fileInfo)
+	err := filepath.WalkDir(t.dir, func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(t.dir, fullPath)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = fileInfo{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return files, nil
+	}
+	return files, err
+}
+
+func (t *localTree) open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(t.path(name))
+}
+
+// write downloads into a temporary file which replaces name once it's complete. The file is
+// given the modification time of the remote file.
+func (t *localTree) write(ctx context.Context, name string, contents io.ReadCloser, info fileInfo) (err error) {
+	defer contents.Close()
+
+	dest := t.path(name)
+	dir, base := filepath.Split(dest)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := io.Copy(tmp, contents); err != nil {
+		return fmt.Errorf("downloading: %w", err)
+	}
+	if err := contents.Close(); err != nil {
+		return fmt.Errorf("downloading: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if !info.modTime.IsZero() {
+		if err := os.Chtimes(tmp.Name(), info.modTime, info.modTime); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+func (t *localTree) remove(ctx context.Context, name string) error {
+	return os.Remove(t.path(name))
+}
+
+func (t *localTree) path(name string) string {
+	return filepath.Join(t.dir, filepath.FromSlash(name))
+}
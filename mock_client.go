@@ -6,11 +6,15 @@ package go_ftp
 
 import (
 	"cmp"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -27,14 +31,29 @@ type MockClient struct {
 	OpenErr   error
 	ReaderErr error
 
+	StatErr error
+
 	DeleteErr     error
 	UploadFileErr error
+	AppendFileErr error
+
+	CreateErr   error
+	OpenFileErr error
+
+	RenameErr error
+	MoveErr   error
+
+	MkdirErr     error
+	MkdirAllErr  error
+	RemoveDirErr error
+	RemoveAllErr error
 
+	ReadDirErr   error
 	ListFilesErr error
 	WalkErr      error
 }
 
-var _ Client = (&MockClient{})
+var _ ClientContext = (&MockClient{})
 
 func NewMockClient(t *testing.T) *MockClient {
 	return &MockClient{
@@ -54,55 +73,300 @@ func (c *MockClient) Close() error {
 	return cmp.Or(c.CloseErr, c.Err)
 }
 
-func (c *MockClient) Reader(path string) (*File, error) {
+func (c *MockClient) Reader(path string, opts ...ReadOption) (*File, error) {
 	if c.Err != nil || c.ReaderErr != nil {
 		return nil, cmp.Or(c.ReaderErr, c.Err)
 	}
-	return c.Open(path)
+	return c.Open(path, opts...)
 }
 
-func (c *MockClient) Open(path string) (*File, error) {
+func (c *MockClient) Open(path string, opts ...ReadOption) (_ *File, err error) {
 	if c.Err != nil || c.OpenErr != nil {
 		return nil, cmp.Or(c.OpenErr, c.Err)
 	}
+
+	defer wrapLocalError(&err, "open", path)
+
 	file, err := os.Open(filepath.Join(c.root, path))
 	if err != nil {
 		return nil, err
 	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	var contents io.ReadCloser = file
+	if options := newReadOptions(opts); options.progress != nil {
+		contents = startProgress(path, info.Size(), *options.progress).reader(file, true)
+	}
+
 	_, name := filepath.Split(path)
 	return &File{
 		Filename: name,
-		Contents: file,
+		Contents: contents,
+		ModTime:  info.ModTime(),
+		fileinfo: info,
 	}, nil
 }
 
-func (c *MockClient) Delete(path string) error {
+func (c *MockClient) Stat(path string) (_ fs.FileInfo, err error) {
+	if c.Err != nil || c.StatErr != nil {
+		return nil, cmp.Or(c.StatErr, c.Err)
+	}
+
+	defer wrapLocalError(&err, "stat", path)
+
+	return os.Stat(filepath.Join(c.root, path))
+}
+
+func (c *MockClient) Delete(path string) (err error) {
 	if c.Err != nil || c.DeleteErr != nil {
 		return cmp.Or(c.DeleteErr, c.Err)
 	}
+
+	defer wrapLocalError(&err, "delete", path)
+
 	return os.Remove(filepath.Join(c.root, path))
 }
 
-func (c *MockClient) UploadFile(path string, contents io.ReadCloser) error {
+// UploadFile writes contents to path. Like the FTP client the directory of path must exist
+// unless CreateParentDirs is given.
+func (c *MockClient) UploadFile(path string, contents io.ReadCloser, opts ...UploadOption) (err error) {
+	defer contents.Close()
+
 	if c.Err != nil || c.UploadFileErr != nil {
 		return cmp.Or(c.UploadFileErr, c.Err)
 	}
 
-	dir, _ := filepath.Split(path)
-	if err := os.MkdirAll(filepath.Join(c.root, dir), 0777); err != nil {
+	defer wrapLocalError(&err, "upload", path)
+
+	options := newUploadOptions(opts)
+	if options.atomic != nil && options.resume {
+		return errors.New("upload: AtomicUpload and ResumeUpload can't be combined")
+	}
+	if options.progress != nil {
+		tracker := startProgress(path, uploadSize(contents), *options.progress)
+		defer func() { tracker.finish(err) }()
+		contents = tracker.reader(contents, false)
+	}
+
+	dir, filename := filepath.Split(path)
+	if options.createParentDirs {
+		if err := os.MkdirAll(filepath.Join(c.root, dir), 0777); err != nil {
+			return err
+		}
+	}
+
+	if options.resume {
+		return c.resumeUpload(path, contents)
+	}
+
+	bs, err := io.ReadAll(contents)
+	if err != nil {
 		return err
 	}
 
-	bs, _ := io.ReadAll(contents)
+	if options.atomic != nil {
+		tempPath := filepath.Join(c.root, dir, options.atomic.tempName(filename))
+		if err := os.MkdirAll(filepath.Dir(tempPath), 0777); err != nil {
+			return err
+		}
+		if err := os.WriteFile(tempPath, bs, 0600); err != nil {
+			os.Remove(tempPath)
+			return err
+		}
+		return os.Rename(tempPath, filepath.Join(c.root, path))
+	}
 
 	return os.WriteFile(filepath.Join(c.root, path), bs, 0600)
 }
 
-func (c *MockClient) ListFiles(dir string) ([]string, error) {
+func (c *MockClient) resumeUpload(path string, contents io.Reader) error {
+	seeker, ok := contents.(io.Seeker)
+	if !ok {
+		return errors.New("resuming an upload requires contents to implement io.Seeker")
+	}
+
+	fd, err := os.OpenFile(filepath.Join(c.root, path), os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	offset, err := fd.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(fd, contents)
+	return err
+}
+
+func (c *MockClient) AppendFile(path string, contents io.ReadCloser, opts ...UploadOption) (err error) {
+	defer contents.Close()
+
+	if c.Err != nil || c.AppendFileErr != nil {
+		return cmp.Or(c.AppendFileErr, c.Err)
+	}
+
+	defer wrapLocalError(&err, "append", path)
+
+	options := newUploadOptions(opts)
+	if options.atomic != nil || options.resume {
+		return errors.New("append: only CreateParentDirs is supported")
+	}
+	if options.createParentDirs {
+		dir, _ := filepath.Split(path)
+		if err := os.MkdirAll(filepath.Join(c.root, dir), 0777); err != nil {
+			return err
+		}
+	}
+
+	fd, err := os.OpenFile(filepath.Join(c.root, path), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fd, contents)
+	return errors.Join(err, fd.Close())
+}
+
+func (c *MockClient) Create(path string, opts ...UploadOption) (io.WriteCloser, error) {
+	if c.Err != nil || c.CreateErr != nil {
+		return nil, cmp.Or(c.CreateErr, c.Err)
+	}
+	return c.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, opts...)
+}
+
+// OpenFile returns a writer which saves the file once it's closed.
+func (c *MockClient) OpenFile(path string, flag int, opts ...UploadOption) (_ io.WriteCloser, err error) {
+	if c.Err != nil || c.OpenFileErr != nil {
+		return nil, cmp.Or(c.OpenFileErr, c.Err)
+	}
+
+	defer wrapLocalError(&err, "open", path)
+
+	if err := checkOpenFlags(flag, newUploadOptions(opts)); err != nil {
+		return nil, err
+	}
+
+	_, err = os.Stat(filepath.Join(c.root, path))
+	switch {
+	case err == nil && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, fs.ErrExist
+	case err != nil && flag&os.O_CREATE == 0:
+		return nil, err
+	}
+
+	return newFileWriter(func(r io.Reader) error {
+		if flag&os.O_APPEND != 0 {
+			return c.AppendFile(path, io.NopCloser(r), opts...)
+		}
+		return c.UploadFile(path, io.NopCloser(r), opts...)
+	}), nil
+}
+
+func (c *MockClient) Rename(oldPath, newPath string, opts ...RenameOption) (err error) {
+	if c.Err != nil || c.RenameErr != nil {
+		return cmp.Or(c.RenameErr, c.Err)
+	}
+
+	defer wrapLocalError(&err, "rename", oldPath)
+
+	return c.rename(oldPath, newPath, newRenameOptions(opts))
+}
+
+func (c *MockClient) Move(oldPath, newPath string, opts ...RenameOption) (err error) {
+	if c.Err != nil || c.MoveErr != nil {
+		return cmp.Or(c.MoveErr, c.Err)
+	}
+
+	defer wrapLocalError(&err, "move", oldPath)
+
+	dir, _ := filepath.Split(newPath)
+	if err := os.MkdirAll(filepath.Join(c.root, dir), 0777); err != nil {
+		return err
+	}
+	return c.rename(oldPath, newPath, newRenameOptions(opts))
+}
+
+func (c *MockClient) rename(oldPath, newPath string, opts renameOptions) error {
+	if _, err := os.Stat(filepath.Join(c.root, newPath)); err == nil && !opts.overwrite {
+		return &PathError{Op: "rename", Path: newPath, Err: fs.ErrExist}
+	}
+	return os.Rename(filepath.Join(c.root, oldPath), filepath.Join(c.root, newPath))
+}
+
+func (c *MockClient) Mkdir(path string) (err error) {
+	if c.Err != nil || c.MkdirErr != nil {
+		return cmp.Or(c.MkdirErr, c.Err)
+	}
+
+	defer wrapLocalError(&err, "mkdir", path)
+
+	return os.Mkdir(filepath.Join(c.root, path), 0777)
+}
+
+func (c *MockClient) MkdirAll(path string) (err error) {
+	if c.Err != nil || c.MkdirAllErr != nil {
+		return cmp.Or(c.MkdirAllErr, c.Err)
+	}
+
+	defer wrapLocalError(&err, "mkdir", path)
+
+	return os.MkdirAll(filepath.Join(c.root, path), 0777)
+}
+
+func (c *MockClient) RemoveDir(path string) (err error) {
+	if c.Err != nil || c.RemoveDirErr != nil {
+		return cmp.Or(c.RemoveDirErr, c.Err)
+	}
+
+	defer wrapLocalError(&err, "rmdir", path)
+
+	where := filepath.Join(c.root, path)
+	info, err := os.Stat(where)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return syscall.ENOTDIR
+	}
+	return os.Remove(where)
+}
+
+func (c *MockClient) RemoveAll(path string) (err error) {
+	if c.Err != nil || c.RemoveAllErr != nil {
+		return cmp.Or(c.RemoveAllErr, c.Err)
+	}
+
+	defer wrapLocalError(&err, "remove", path)
+
+	if path == "" || path == "/" || path == "." {
+		return fmt.Errorf("FTP client: refusing to remove %q", path)
+	}
+	return os.RemoveAll(filepath.Join(c.root, path))
+}
+
+func (c *MockClient) ReadDir(dir string) (_ []fs.DirEntry, err error) {
+	if c.Err != nil || c.ReadDirErr != nil {
+		return nil, cmp.Or(c.ReadDirErr, c.Err)
+	}
+
+	defer wrapLocalError(&err, "readdir", dir)
+
+	return os.ReadDir(filepath.Join(c.root, dir))
+}
+
+func (c *MockClient) ListFiles(dir string) (_ []string, err error) {
 	if c.Err != nil || c.ListFilesErr != nil {
 		return nil, cmp.Or(c.ListFilesErr, c.Err)
 	}
 
+	defer wrapLocalError(&err, "list", dir)
+
 	os.MkdirAll(filepath.Join(c.root, dir), 0777)
 
 	fds, err := os.ReadDir(filepath.Join(c.root, dir))
@@ -130,3 +394,166 @@ func (c *MockClient) Walk(dir string, fn fs.WalkDirFunc) error {
 
 	return fs.WalkDir(os.DirFS(d), ".", fn)
 }
+
+// wrapLocalError returns an error from the local filesystem as a *PathError like the FTP
+// client's errors, without the temporary directory in its path.
+func wrapLocalError(errp *error, op, path string) {
+	err := *errp
+	if err == nil {
+		return
+	}
+	if pathErr, ok := err.(*fs.PathError); ok {
+		err = pathErr.Err
+	}
+	if linkErr, ok := err.(*os.LinkError); ok {
+		err = linkErr.Err
+	}
+	*errp = newPathError(op, path, err)
+}
+
+func (c *MockClient) PingContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Ping()
+}
+
+func (c *MockClient) OpenContext(ctx context.Context, path string, opts ...ReadOption) (*File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Open(path, opts...)
+}
+
+// ReaderContext returns a File whose Contents fail to read once ctx is done.
+func (c *MockClient) ReaderContext(ctx context.Context, path string, opts ...ReadOption) (*File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	file, err := c.Reader(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	file.Contents = &contextReader{ctx: ctx, ReadCloser: file.Contents}
+	return file, nil
+}
+
+func (c *MockClient) StatContext(ctx context.Context, path string) (fs.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Stat(path)
+}
+
+func (c *MockClient) DeleteContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Delete(path)
+}
+
+func (c *MockClient) UploadFileContext(ctx context.Context, path string, contents io.ReadCloser, opts ...UploadOption) error {
+	if err := ctx.Err(); err != nil {
+		contents.Close()
+		return err
+	}
+	// Keep the size of contents for UploadProgress
+	sized := &sizedReader{ReadCloser: &contextReader{ctx: ctx, ReadCloser: contents}, total: uploadSize(contents)}
+	var r io.ReadCloser = sized
+	if seeker, ok := contents.(io.Seeker); ok {
+		// Keep contents seekable for ResumeUpload
+		r = struct {
+			*sizedReader
+			io.Seeker
+		}{sized, seeker}
+	}
+	return c.UploadFile(path, r, opts...)
+}
+
+func (c *MockClient) AppendFileContext(ctx context.Context, path string, contents io.ReadCloser, opts ...UploadOption) error {
+	if err := ctx.Err(); err != nil {
+		contents.Close()
+		return err
+	}
+	return c.AppendFile(path, &contextReader{ctx: ctx, ReadCloser: contents}, opts...)
+}
+
+func (c *MockClient) CreateContext(ctx context.Context, path string, opts ...UploadOption) (io.WriteCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Create(path, opts...)
+}
+
+func (c *MockClient) OpenFileContext(ctx context.Context, path string, flag int, opts ...UploadOption) (io.WriteCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.OpenFile(path, flag, opts...)
+}
+
+func (c *MockClient) RenameContext(ctx context.Context, oldPath, newPath string, opts ...RenameOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Rename(oldPath, newPath, opts...)
+}
+
+func (c *MockClient) MoveContext(ctx context.Context, oldPath, newPath string, opts ...RenameOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Move(oldPath, newPath, opts...)
+}
+
+func (c *MockClient) MkdirContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Mkdir(path)
+}
+
+func (c *MockClient) MkdirAllContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.MkdirAll(path)
+}
+
+func (c *MockClient) RemoveDirContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.RemoveDir(path)
+}
+
+func (c *MockClient) RemoveAllContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.RemoveAll(path)
+}
+
+func (c *MockClient) ReadDirContext(ctx context.Context, dir string) ([]fs.DirEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.ReadDir(dir)
+}
+
+func (c *MockClient) ListFilesContext(ctx context.Context, dir string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.ListFiles(dir)
+}
+
+// WalkContext stops walking with ctx's error once ctx is done.
+func (c *MockClient) WalkContext(ctx context.Context, dir string, fn fs.WalkDirFunc) error {
+	return c.Walk(dir, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fn(path, d, err)
+	})
+}
@@ -5,9 +5,12 @@
 package go_ftp_test
 
 import (
+	"context"
 	"errors"
 	"io"
 	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -46,6 +49,7 @@ func TestMockClient_ListAndOpenFiles(t *testing.T) {
 	require.NoError(t, client.Ping())
 	defer require.NoError(t, client.Close())
 
+	require.NoError(t, client.MkdirAll("/path"))
 	require.NoError(t, client.UploadFile("/path/f1.txt", io.NopCloser(strings.NewReader("foo"))))
 	require.NoError(t, client.UploadFile("/path/f2.txt", io.NopCloser(strings.NewReader("foo"))))
 
@@ -86,3 +90,254 @@ func TestMockClient_ListAndOpenFiles(t *testing.T) {
 	require.NoError(t, err)
 	require.Contains(t, walkedFiles, "f1.txt", "f2.txt")
 }
+
+func TestMockClient_Context(t *testing.T) {
+	client := ftp.NewMockClient(t)
+	require.NoError(t, client.UploadFile("/exists.txt", io.NopCloser(strings.NewReader("contents"))))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	file, err := client.ReaderContext(ctx, "/exists.txt")
+	require.NoError(t, err)
+	t.Cleanup(func() { file.Close() })
+
+	cancel()
+
+	_, err = io.ReadAll(file)
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.ErrorIs(t, client.PingContext(ctx), context.Canceled)
+
+	_, err = client.OpenContext(ctx, "/exists.txt")
+	require.ErrorIs(t, err, context.Canceled)
+
+	err = client.UploadFileContext(ctx, "/other.txt", io.NopCloser(strings.NewReader("other")))
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = client.ListFilesContext(ctx, "/")
+	require.ErrorIs(t, err, context.Canceled)
+
+	err = client.WalkContext(ctx, "/", func(path string, d fs.DirEntry, err error) error {
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.ErrorIs(t, client.DeleteContext(ctx, "/exists.txt"), context.Canceled)
+}
+
+func TestMockClient_Stat(t *testing.T) {
+	client := ftp.NewMockClient(t)
+
+	require.NoError(t, client.UploadFile("/path/f1.txt", io.NopCloser(strings.NewReader("foo")), ftp.CreateParentDirs()))
+
+	info, err := client.Stat("/path/f1.txt")
+	require.NoError(t, err)
+	require.Equal(t, "f1.txt", info.Name())
+	require.Equal(t, int64(3), info.Size())
+
+	file, err := client.Open("/path/f1.txt")
+	require.NoError(t, err)
+	defer file.Close()
+
+	stat, err := file.Stat()
+	require.NoError(t, err)
+	require.Equal(t, info.ModTime(), stat.ModTime())
+	require.Equal(t, info.ModTime(), file.ModTime)
+
+	_, err = client.Stat("/missing.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestMockClient_PathErrors(t *testing.T) {
+	client := ftp.NewMockClient(t)
+
+	_, err := client.Open("/missing.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	var pathErr *ftp.PathError
+	require.ErrorAs(t, err, &pathErr)
+	require.Equal(t, "open /missing.txt: no such file or directory", err.Error())
+
+	err = client.UploadFile("/missing/a.txt", io.NopCloser(strings.NewReader("a")))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.ErrorAs(t, err, &pathErr)
+	require.Equal(t, "upload", pathErr.Op)
+
+	require.NoError(t, client.Mkdir("/dir"))
+	require.ErrorIs(t, client.Mkdir("/dir"), fs.ErrExist)
+
+	// Configured errors are returned as is
+	client.OpenErr = errors.New("bad")
+	_, err = client.Open("/missing.txt")
+	require.Equal(t, client.OpenErr, err)
+}
+
+func TestMockClient_Rename(t *testing.T) {
+	client := ftp.NewMockClient(t)
+
+	require.NoError(t, client.UploadFile("/a.txt", io.NopCloser(strings.NewReader("a"))))
+	require.NoError(t, client.UploadFile("/b.txt", io.NopCloser(strings.NewReader("b"))))
+
+	err := client.Rename("/a.txt", "/b.txt")
+	require.ErrorIs(t, err, fs.ErrExist)
+
+	require.NoError(t, client.Rename("/a.txt", "/b.txt", ftp.OverwriteExisting()))
+
+	require.NoError(t, client.Move("/b.txt", "/archive/2023/b.txt"))
+
+	paths, err := client.ListFiles("/archive/2023")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/archive/2023/b.txt"}, paths)
+
+	file, err := client.Open("/archive/2023/b.txt")
+	require.NoError(t, err)
+	defer file.Close()
+
+	bs, _ := io.ReadAll(file)
+	require.Equal(t, "a", string(bs))
+}
+
+func TestMockClient_Directories(t *testing.T) {
+	client := ftp.NewMockClient(t)
+
+	// Uploads into missing directories fail like they do on FTP servers
+	err := client.UploadFile("/a/b/c.txt", io.NopCloser(strings.NewReader("c")))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, client.UploadFile("/a/b/c.txt", io.NopCloser(strings.NewReader("c")), ftp.CreateParentDirs()))
+
+	require.NoError(t, client.Mkdir("/a/empty"))
+	require.Error(t, client.Mkdir("/x/y"))
+	require.NoError(t, client.MkdirAll("/x/y"))
+	require.NoError(t, client.MkdirAll("/x/y"))
+
+	require.NoError(t, client.RemoveDir("/a/empty"))
+	require.Error(t, client.RemoveDir("/a/b"))
+	require.Error(t, client.RemoveDir("/a/b/c.txt"))
+
+	require.NoError(t, client.RemoveAll("/a"))
+	require.NoError(t, client.RemoveAll("/a"))
+
+	_, err = client.Stat("/a/b/c.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestMockClient_AtomicUpload(t *testing.T) {
+	client := ftp.NewMockClient(t)
+
+	atomic := ftp.AtomicUpload(ftp.AtomicUploadConfig{Dir: ".uploading"})
+	require.NoError(t, client.UploadFile("/a.txt", io.NopCloser(strings.NewReader("a")), atomic))
+
+	file, err := client.Open("/a.txt")
+	require.NoError(t, err)
+	defer file.Close()
+
+	bs, _ := io.ReadAll(file)
+	require.Equal(t, "a", string(bs))
+
+	paths, err := client.ListFiles("/.uploading")
+	require.NoError(t, err)
+	require.Empty(t, paths)
+}
+
+func TestMockClient_AppendAndResumeUpload(t *testing.T) {
+	client := ftp.NewMockClient(t)
+
+	read := func(path string) string {
+		t.Helper()
+		file, err := client.Open(path)
+		require.NoError(t, err)
+		bs, _ := io.ReadAll(file)
+		require.NoError(t, file.Close())
+		return string(bs)
+	}
+
+	require.NoError(t, client.AppendFile("/a.txt", io.NopCloser(strings.NewReader("hello"))))
+	require.NoError(t, client.AppendFile("/a.txt", io.NopCloser(strings.NewReader(" world"))))
+	require.Equal(t, "hello world", read("/a.txt"))
+
+	require.NoError(t, client.UploadFile("/b.txt", io.NopCloser(strings.NewReader("hello"))))
+
+	source := filepath.Join(t.TempDir(), "b.txt")
+	require.NoError(t, os.WriteFile(source, []byte("HELLO world"), 0600))
+	fd, err := os.Open(source)
+	require.NoError(t, err)
+
+	// Only the missing part is uploaded
+	require.NoError(t, client.UploadFileContext(context.Background(), "/b.txt", fd, ftp.ResumeUpload()))
+	require.Equal(t, "hello world", read("/b.txt"))
+
+	err = client.UploadFile("/b.txt", io.NopCloser(strings.NewReader("hello")), ftp.ResumeUpload())
+	require.ErrorContains(t, err, "io.Seeker")
+}
+
+func TestMockClient_Create(t *testing.T) {
+	client := ftp.NewMockClient(t)
+
+	w, err := client.Create("/a.txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	w, err = client.OpenFile("/a.txt", os.O_WRONLY|os.O_APPEND)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(" world"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	file, err := client.Open("/a.txt")
+	require.NoError(t, err)
+	defer file.Close()
+
+	bs, _ := io.ReadAll(file)
+	require.Equal(t, "hello world", string(bs))
+
+	_, err = client.OpenFile("/a.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_TRUNC)
+	require.ErrorIs(t, err, fs.ErrExist)
+
+	_, err = client.OpenFile("/b.txt", os.O_WRONLY|os.O_TRUNC)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	w, err = client.Create("/missing/b.txt")
+	require.NoError(t, err)
+	w.Write([]byte("b"))
+	require.ErrorIs(t, w.Close(), fs.ErrNotExist)
+}
+
+func TestMockClient_Progress(t *testing.T) {
+	client := ftp.NewMockClient(t)
+
+	var reports []ftp.Progress
+	record := func(p ftp.Progress) { reports = append(reports, p) }
+
+	err := client.UploadFile("/a.txt", io.NopCloser(strings.NewReader("hello")), ftp.UploadProgress(0, record))
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	require.True(t, reports[0].Done)
+	require.Equal(t, "/a.txt", reports[0].Path)
+	require.Equal(t, int64(5), reports[0].Bytes)
+	require.Equal(t, int64(-1), reports[0].Total) // io.NopCloser hides the size
+
+	// The size of files is kept by UploadFileContext
+	fd, err := os.Open(filepath.Join("testdata", "ftp-server", "first.txt"))
+	require.NoError(t, err)
+	reports = nil
+	err = client.UploadFileContext(context.Background(), "/b.txt", fd, ftp.UploadProgress(0, record))
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	require.Equal(t, int64(12), reports[0].Total)
+
+	reports = nil
+	file, err := client.Open("/a.txt", ftp.DownloadProgress(0, record))
+	require.NoError(t, err)
+	bs, err := io.ReadAll(file)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(bs))
+	require.NoError(t, file.Close())
+
+	require.Len(t, reports, 1)
+	require.True(t, reports[0].Done)
+	require.Equal(t, int64(5), reports[0].Bytes)
+	require.Equal(t, int64(5), reports[0].Total)
+}
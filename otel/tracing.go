@@ -0,0 +1,317 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package otel traces the operations of a go_ftp client with OpenTelemetry.
+//
+//	client, err := go_ftp.NewClientContext(ctx, cfg)
+//	...
+//	client = otel.NewClient(client, cfg.Hostname)
+package otel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/textproto"
+	"sync"
+	"sync/atomic"
+
+	go_ftp "github.com/moov-io/go-ftp"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/moov-io/go-ftp/otel"
+
+// Attributes recorded on spans, along with server.address.
+const (
+	PathKey      = attribute.Key("ftp.path")
+	BytesKey     = attribute.Key("ftp.bytes")
+	FilesKey     = attribute.Key("ftp.files")
+	ReplyCodeKey = attribute.Key("ftp.reply_code")
+)
+
+// Option configures NewClient.
+type Option func(*tracedClient)
+
+// WithTracerProvider creates spans from tp instead of the global TracerProvider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *tracedClient) {
+		c.tracer = tp.Tracer(instrumentationName)
+	}
+}
+
+// NewClient returns client with a span for Open, Reader, UploadFile, Walk, ListFiles and Delete
+// and their Context variants. Spans are children of the span in the ctx of Context methods.
+// The span of Reader ends once the returned File is closed.
+//
+// host is recorded as the server.address attribute. Other methods are passed to client
+// without tracing.
+func NewClient(client go_ftp.ClientContext, host string, opts ...Option) go_ftp.ClientContext {
+	c := &tracedClient{
+		ClientContext: client,
+		tracer:        otel.GetTracerProvider().Tracer(instrumentationName),
+		host:          host,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type tracedClient struct {
+	go_ftp.ClientContext
+
+	tracer trace.Tracer
+	host   string
+}
+
+func (c *tracedClient) start(ctx context.Context, name, path string) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, "ftp."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("server.address", c.host),
+			PathKey.String(path),
+		),
+	)
+}
+
+// end finishes span with the outcome of err, including the FTP reply code of a failed command.
+func end(span trace.Span, err error) {
+	if err != nil {
+		var pathErr *go_ftp.PathError
+		var tpErr *textproto.Error
+		switch {
+		case errors.As(err, &pathErr) && pathErr.Code > 0:
+			span.SetAttributes(ReplyCodeKey.Int(pathErr.Code))
+		case errors.As(err, &tpErr):
+			span.SetAttributes(ReplyCodeKey.Int(tpErr.Code))
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (c *tracedClient) Open(path string, opts ...go_ftp.ReadOption) (*go_ftp.File, error) {
+	return c.OpenContext(go_ftp.DefaultContext(), path, opts...)
+}
+
+func (c *tracedClient) OpenContext(ctx context.Context, path string, opts ...go_ftp.ReadOption) (_ *go_ftp.File, err error) {
+	ctx, span := c.start(ctx, "Open", path)
+	defer func() { end(span, err) }()
+
+	file, err := c.ClientContext.OpenContext(ctx, path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if sizer, ok := file.Contents.(interface{ Size() int64 }); ok {
+		span.SetAttributes(BytesKey.Int64(sizer.Size()))
+	} else if info, _ := file.Stat(); info != nil {
+		span.SetAttributes(BytesKey.Int64(info.Size()))
+	}
+	return file, nil
+}
+
+func (c *tracedClient) Reader(path string, opts ...go_ftp.ReadOption) (*go_ftp.File, error) {
+	return c.ReaderContext(go_ftp.DefaultContext(), path, opts...)
+}
+
+func (c *tracedClient) ReaderContext(ctx context.Context, path string, opts ...go_ftp.ReadOption) (*go_ftp.File, error) {
+	ctx, span := c.start(ctx, "Reader", path)
+
+	file, err := c.ClientContext.ReaderContext(ctx, path, opts...)
+	if err != nil {
+		end(span, err)
+		return nil, err
+	}
+
+	contents := &spanReader{ReadCloser: file.Contents, span: span}
+	file.Contents = contents
+	if ra, ok := contents.ReadCloser.(randomAccess); ok {
+		// Keep File.ReadAt and File.Seek working
+		file.Contents = &spanRandomReader{spanReader: contents, ra: ra}
+	}
+	return file, nil
+}
+
+type randomAccess interface {
+	io.ReaderAt
+	io.Seeker
+}
+
+// spanReader counts the bytes read and ends span once it's closed.
+type spanReader struct {
+	io.ReadCloser
+
+	span trace.Span
+	n    atomic.Int64
+
+	mu      sync.Mutex
+	readErr error
+	once    sync.Once
+}
+
+func (r *spanReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.observe(n, err)
+	return n, err
+}
+
+func (r *spanReader) observe(n int, err error) {
+	r.n.Add(int64(n))
+	if err != nil && err != io.EOF {
+		r.mu.Lock()
+		r.readErr = err
+		r.mu.Unlock()
+	}
+}
+
+func (r *spanReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.span.SetAttributes(BytesKey.Int64(r.n.Load()))
+		end(r.span, errors.Join(r.readErr, err))
+	})
+	return err
+}
+
+type spanRandomReader struct {
+	*spanReader
+
+	ra randomAccess
+}
+
+func (r *spanRandomReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.ra.ReadAt(p, off)
+	r.observe(n, err)
+	return n, err
+}
+
+func (r *spanRandomReader) Seek(offset int64, whence int) (int64, error) {
+	return r.ra.Seek(offset, whence)
+}
+
+func (c *tracedClient) UploadFile(path string, contents io.ReadCloser, opts ...go_ftp.UploadOption) error {
+	return c.UploadFileContext(go_ftp.DefaultContext(), path, contents, opts...)
+}
+
+func (c *tracedClient) UploadFileContext(ctx context.Context, path string, contents io.ReadCloser, opts ...go_ftp.UploadOption) (err error) {
+	ctx, span := c.start(ctx, "UploadFile", path)
+	defer func() { end(span, err) }()
+
+	counter := &countingReader{ReadCloser: contents}
+	defer func() { span.SetAttributes(BytesKey.Int64(counter.n)) }()
+
+	return c.ClientContext.UploadFileContext(ctx, path, counter.wrap(contents), opts...)
+}
+
+type countingReader struct {
+	io.ReadCloser
+
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+type statter interface {
+	Stat() (fs.FileInfo, error)
+}
+
+type lenner interface {
+	Len() int
+}
+
+// wrap returns r with the methods of contents the core uses for uploads: Stat or Len to find
+// the size for progress reports, and Seek to resume and retry them.
+func (r *countingReader) wrap(contents io.ReadCloser) io.ReadCloser {
+	seeker, canSeek := contents.(io.Seeker)
+	switch src := contents.(type) {
+	case statter:
+		if canSeek {
+			return &struct {
+				*countingReader
+				statter
+				io.Seeker
+			}{r, src, seeker}
+		}
+		return &struct {
+			*countingReader
+			statter
+		}{r, src}
+	case lenner:
+		if canSeek {
+			return &struct {
+				*countingReader
+				lenner
+				io.Seeker
+			}{r, src, seeker}
+		}
+		return &struct {
+			*countingReader
+			lenner
+		}{r, src}
+	}
+	if canSeek {
+		return &struct {
+			*countingReader
+			io.Seeker
+		}{r, seeker}
+	}
+	return r
+}
+
+func (c *tracedClient) Walk(dir string, fn fs.WalkDirFunc) error {
+	return c.WalkContext(go_ftp.DefaultContext(), dir, fn)
+}
+
+func (c *tracedClient) WalkContext(ctx context.Context, dir string, fn fs.WalkDirFunc) (err error) {
+	ctx, span := c.start(ctx, "Walk", dir)
+	defer func() { end(span, err) }()
+
+	var files int
+	defer func() { span.SetAttributes(FilesKey.Int(files)) }()
+
+	return c.ClientContext.WalkContext(ctx, dir, func(path string, d fs.DirEntry, err error) error {
+		if d != nil && !d.IsDir() {
+			files++
+		}
+		return fn(path, d, err)
+	})
+}
+
+func (c *tracedClient) ListFiles(dir string) ([]string, error) {
+	return c.ListFilesContext(go_ftp.DefaultContext(), dir)
+}
+
+func (c *tracedClient) ListFilesContext(ctx context.Context, dir string) (_ []string, err error) {
+	ctx, span := c.start(ctx, "ListFiles", dir)
+	defer func() { end(span, err) }()
+
+	files, err := c.ClientContext.ListFilesContext(ctx, dir)
+	span.SetAttributes(FilesKey.Int(len(files)))
+	return files, err
+}
+
+func (c *tracedClient) Delete(path string) error {
+	return c.DeleteContext(go_ftp.DefaultContext(), path)
+}
+
+func (c *tracedClient) DeleteContext(ctx context.Context, path string) (err error) {
+	ctx, span := c.start(ctx, "Delete", path)
+	defer func() { end(span, err) }()
+
+	return c.ClientContext.DeleteContext(ctx, path)
+}
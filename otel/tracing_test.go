@@ -0,0 +1,189 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package otel_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	go_ftp "github.com/moov-io/go-ftp"
+	ftpotel "github.com/moov-io/go-ftp/otel"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewClient(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	mock := go_ftp.NewMockClient(t)
+	client := ftpotel.NewClient(mock, "ftp.example.com:21", ftpotel.WithTracerProvider(tp))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+
+	err := client.UploadFileContext(ctx, "/upload.txt", io.NopCloser(strings.NewReader("hello, world")))
+	require.NoError(t, err)
+
+	file, err := client.OpenContext(ctx, "/upload.txt")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	file, err = client.ReaderContext(ctx, "/upload.txt")
+	require.NoError(t, err)
+	require.Len(t, exporter.GetSpans(), 2) // the Reader span ends once the file is closed
+
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(file, buf)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	files, err := client.ListFilesContext(ctx, "/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/upload.txt"}, files)
+
+	err = client.WalkContext(ctx, "/", func(path string, d fs.DirEntry, err error) error {
+		return err
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, client.DeleteContext(ctx, "/upload.txt"))
+
+	mock.DeleteErr = &go_ftp.PathError{Op: "delete", Path: "/upload.txt", Code: 550, Err: &textproto.Error{Code: 550, Msg: "Permission denied"}}
+	require.Error(t, client.Delete("/upload.txt"))
+
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 8)
+
+	type span struct {
+		name       string
+		attributes map[attribute.Key]attribute.Value
+		status     codes.Code
+		parent     bool
+	}
+	var got []span
+	for _, s := range spans[:7] {
+		attrs := make(map[attribute.Key]attribute.Value)
+		for _, kv := range s.Attributes {
+			attrs[kv.Key] = kv.Value
+		}
+		require.Equal(t, "ftp.example.com:21", attrs["server.address"].AsString())
+		got = append(got, span{
+			name:       s.Name,
+			attributes: attrs,
+			status:     s.Status.Code,
+			parent:     s.Parent.SpanID() == parent.SpanContext().SpanID(),
+		})
+	}
+
+	require.Equal(t, "ftp.UploadFile", got[0].name)
+	require.Equal(t, "/upload.txt", got[0].attributes[ftpotel.PathKey].AsString())
+	require.Equal(t, int64(12), got[0].attributes[ftpotel.BytesKey].AsInt64())
+
+	require.Equal(t, "ftp.Open", got[1].name)
+	require.Equal(t, int64(12), got[1].attributes[ftpotel.BytesKey].AsInt64())
+
+	require.Equal(t, "ftp.Reader", got[2].name)
+	require.Equal(t, int64(5), got[2].attributes[ftpotel.BytesKey].AsInt64())
+
+	require.Equal(t, "ftp.ListFiles", got[3].name)
+	require.Equal(t, int64(1), got[3].attributes[ftpotel.FilesKey].AsInt64())
+
+	require.Equal(t, "ftp.Walk", got[4].name)
+	require.Equal(t, int64(1), got[4].attributes[ftpotel.FilesKey].AsInt64())
+
+	require.Equal(t, "ftp.Delete", got[5].name)
+	require.Equal(t, codes.Unset, got[5].status)
+
+	for _, s := range got[:6] {
+		require.True(t, s.parent, s.name)
+	}
+
+	// Failures record the reply code
+	require.Equal(t, "ftp.Delete", got[6].name)
+	require.False(t, got[6].parent)
+	require.Equal(t, codes.Error, got[6].status)
+	require.Equal(t, int64(550), got[6].attributes[ftpotel.ReplyCodeKey].AsInt64())
+	require.Len(t, spans[6].Events, 1) // the recorded error
+}
+
+// randomAccessClient returns the *os.File of the mock from ReaderContext, which supports
+// random access like files read with the RandomAccess option.
+type randomAccessClient struct {
+	*go_ftp.MockClient
+}
+
+func (c randomAccessClient) ReaderContext(ctx context.Context, path string, opts ...go_ftp.ReadOption) (*go_ftp.File, error) {
+	return c.Reader(path, opts...)
+}
+
+func TestNewClient_RandomAccess(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	mock := go_ftp.NewMockClient(t)
+	require.NoError(t, mock.UploadFile("/data.txt", io.NopCloser(strings.NewReader("0123456789"))))
+
+	client := ftpotel.NewClient(randomAccessClient{mock}, "ftp.example.com:21", ftpotel.WithTracerProvider(tp))
+	file, err := client.Reader("/data.txt")
+	require.NoError(t, err)
+
+	buf := make([]byte, 3)
+	_, err = file.ReadAt(buf, 7)
+	require.NoError(t, err)
+	require.Equal(t, "789", string(buf))
+
+	_, err = file.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Contains(t, spans[0].Attributes, ftpotel.BytesKey.Int64(3))
+}
+
+type lenReadCloser struct {
+	*bytes.Reader
+}
+
+func (lenReadCloser) Close() error { return nil }
+
+func TestNewClient_UploadProgress(t *testing.T) {
+	client := ftpotel.NewClient(go_ftp.NewMockClient(t), "ftp.example.com:21")
+
+	upload := func(path string, contents io.ReadCloser) go_ftp.Progress {
+		t.Helper()
+		var last go_ftp.Progress
+		err := client.UploadFile(path, contents, go_ftp.UploadProgress(time.Hour, func(p go_ftp.Progress) {
+			last = p
+		}))
+		require.NoError(t, err)
+		return last
+	}
+
+	// The size of uploads is still found from Stat or Len
+	path := filepath.Join(t.TempDir(), "a.txt")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0600))
+	fd, err := os.Open(path)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), upload("/a.txt", fd).Total)
+
+	require.Equal(t, int64(5), upload("/b.txt", lenReadCloser{bytes.NewReader([]byte("hello"))}).Total)
+
+	require.Equal(t, int64(-1), upload("/c.txt", io.NopCloser(strings.NewReader("hello"))).Total)
+}
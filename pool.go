@@ -0,0 +1,251 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// ErrPoolTimeout is returned by methods without a context when every connection stayed in use
+// for the client's Timeout, such as by a Reader which was not read to the end or closed.
+var ErrPoolTimeout = errors.New("timed out waiting for a connection")
+
+// defaultContextKey marks contexts from DefaultContext. It's a value rather than a deadline so
+// transfers which take longer than the wait aren't cancelled.
+type defaultContextKey struct{}
+
+// DefaultContext returns the context the methods without a context pass to their Context
+// variant, which gives up waiting for a connection after ClientConfig.Timeout. Other contexts
+// wait until they're done. Wrappers of a ClientContext can use it to implement the methods
+// without a context the same way.
+func DefaultContext() context.Context {
+	return context.WithValue(context.Background(), defaultContextKey{}, true)
+}
+
+// pool manages the connections to an FTP server. Each connection is checked out by one
+// operation at a time as an ftp.ServerConn is not safe for concurrent use.
+type pool struct {
+	dial func(ctx context.Context) (*serverConn, error)
+
+	// reconnected is called when an idle connection fails its health check, if it's set.
+	reconnected func()
+
+	min, max    int
+	idleTimeout time.Duration
+	waitTimeout time.Duration
+
+	// slots holds a token for each checked out connection, which limits the open
+	// connections to max as idle connections are only created by returning a slot.
+	slots chan struct{}
+
+	mu    sync.Mutex
+	idle  []idleConn // least recently used first
+	timer *time.Timer
+}
+
+type idleConn struct {
+	conn  *serverConn
+	since time.Time
+}
+
+func newPool(cfg ClientConfig, dial func(ctx context.Context) (*serverConn, error)) *pool {
+	max := max(cfg.MaxConnections, cfg.MinConnections, 1)
+	return &pool{
+		dial:        dial,
+		min:         cfg.MinConnections,
+		max:         max,
+		idleTimeout: cfg.IdleTimeout,
+		waitTimeout: cmp.Or(cfg.Timeout, ftp.DefaultDialTimeout),
+		slots:       make(chan struct{}, max),
+	}
+}
+
+// fill checks out n connections at once, which opens connections until at least n exist.
+func (p *pool) fill(ctx context.Context, n int) error {
+	var conns []*serverConn
+	defer func() {
+		for _, conn := range conns {
+			p.put(conn)
+		}
+	}()
+	for len(conns) < min(n, p.max) {
+		conn, err := p.get(ctx)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, conn)
+	}
+	return nil
+}
+
+// get checks out a connection, waiting for one to be returned when max connections are in use.
+// Idle connections are verified with a NOOP command and replaced if they fail.
+func (p *pool) get(ctx context.Context) (*serverConn, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	for {
+		conn := p.pop()
+		if conn == nil {
+			break
+		}
+
+		// Verify the connection works and if not drop through and reconnect
+		stop := conn.watch(ctx)
+		err := conn.NoOp()
+		stop()
+		if err == nil {
+			return conn, nil
+		}
+
+		// Our connection is having issues, so try another or connect again
+		conn.Quit()
+		if p.reconnected != nil {
+			p.reconnected()
+		}
+	}
+
+	conn, err := p.dial(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return conn, nil
+}
+
+// wait takes a slot once one is free. Contexts from DefaultContext, as used by the methods
+// without a context, give up after waitTimeout so they don't block forever.
+func (p *pool) wait(ctx context.Context) error {
+	var timeout <-chan time.Time
+	if ctx.Value(defaultContextKey{}) != nil {
+		timer := time.NewTimer(p.waitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timeout:
+		return fmt.Errorf("%w: all %d connections are in use after %v", ErrPoolTimeout, p.max, p.waitTimeout)
+	}
+}
+
+func (p *pool) pop() *serverConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.idle) == 0 {
+		return nil
+	}
+	last := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return last.conn
+}
+
+// put returns a connection to the pool. Aborted connections are closed instead of being kept.
+func (p *pool) put(conn *serverConn) {
+	defer func() { <-p.slots }()
+
+	if conn.isAborted() {
+		conn.Quit()
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.idle = append(p.idle, idleConn{conn: conn, since: time.Now()})
+	if p.idleTimeout > 0 && p.timer == nil {
+		p.timer = time.AfterFunc(p.idleTimeout, p.evict)
+	}
+}
+
+// evict closes connections which have been idle longer than idleTimeout while keeping
+// min connections open.
+func (p *pool) evict() {
+	p.mu.Lock()
+
+	now := time.Now()
+	open := len(p.idle) + len(p.slots)
+
+	var expired []*serverConn
+	for len(p.idle) > 0 && open > p.min && now.Sub(p.idle[0].since) >= p.idleTimeout {
+		expired = append(expired, p.idle[0].conn)
+		p.idle = p.idle[1:]
+		open--
+	}
+
+	p.timer = nil
+	if len(p.idle) > 0 && open > p.min {
+		p.timer = time.AfterFunc(p.idle[0].since.Add(p.idleTimeout).Sub(now), p.evict)
+	}
+	p.mu.Unlock()
+
+	for _, conn := range expired {
+		conn.Quit()
+	}
+}
+
+// close closes all idle connections. Connections which are checked out are kept open
+// until they are returned. The pool can still be used afterwards.
+func (p *pool) close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.mu.Unlock()
+
+	var errs []error
+	for _, ic := range idle {
+		if err := ic.conn.Quit(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// releaseReader returns its connection to the pool once the response has been read to
+// the end or is closed, whichever happens first.
+type releaseReader struct {
+	io.Reader
+
+	release func() error
+	eof     bool
+}
+
+func (r *releaseReader) Read(p []byte) (int, error) {
+	if r.eof {
+		// The response is closed once released, so keep reporting the end of the file
+		return 0, io.EOF
+	}
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		r.eof = true
+		// Closing the response checks if the transfer was successful
+		if releaseErr := r.release(); releaseErr != nil {
+			return n, releaseErr
+		}
+	}
+	return n, err
+}
+
+func (r *releaseReader) Close() error {
+	return r.release()
+}
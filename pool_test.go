@@ -0,0 +1,109 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool(t *testing.T) {
+	cfg := ClientConfig{
+		Hostname:       "127.0.0.1:2121",
+		Username:       "admin",
+		Password:       "123456",
+		MinConnections: 1,
+		MaxConnections: 3,
+		IdleTimeout:    100 * time.Millisecond,
+	}
+	cc, err := NewClient(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { cc.Close() })
+
+	p := cc.(*client).pool
+	require.Len(t, p.idle, 1)
+
+	// Fill the pool up
+	require.NoError(t, p.fill(context.Background(), 3))
+	require.Len(t, p.idle, 3)
+
+	// Idle connections are closed down to MinConnections
+	require.Eventually(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return len(p.idle) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// Reading a file to the end returns its connection
+	file, err := cc.Reader("first.txt")
+	require.NoError(t, err)
+	require.Len(t, p.slots, 1)
+
+	_, err = io.ReadAll(file)
+	require.NoError(t, err)
+	require.Len(t, p.slots, 0)
+	require.NoError(t, file.Close())
+
+	// Aborted connections are discarded
+	ctx, cancel := context.WithCancel(context.Background())
+	file, err = cc.(ClientContext).ReaderContext(ctx, "/bigdata/large.txt")
+	require.NoError(t, err)
+	cancel()
+	file.Close()
+
+	p.mu.Lock()
+	for _, ic := range p.idle {
+		require.False(t, ic.conn.isAborted())
+	}
+	p.mu.Unlock()
+
+	require.NoError(t, cc.Close())
+	require.Empty(t, p.idle)
+	require.NoError(t, cc.Ping())
+
+	// Idle connections which fail their health check are replaced
+	var reconnects int
+	p.reconnected = func() { reconnects++ }
+	p.mu.Lock()
+	require.Len(t, p.idle, 1)
+	p.idle[0].conn.abort()
+	p.mu.Unlock()
+
+	require.NoError(t, cc.Ping())
+	require.Equal(t, 1, reconnects)
+}
+
+func TestPool_Wait(t *testing.T) {
+	cc, err := NewClient(ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+		Timeout:  200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { cc.Close() })
+
+	// An unread Reader holds the only connection
+	file, err := cc.Reader("first.txt")
+	require.NoError(t, err)
+
+	_, err = cc.Stat("first.txt")
+	require.ErrorIs(t, err, ErrPoolTimeout)
+
+	_, err = cc.(ClientContext).StatContext(DefaultContext(), "first.txt")
+	require.ErrorIs(t, err, ErrPoolTimeout)
+
+	// Other contexts decide how long to wait themselves, even ones which are never done
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		file.Close()
+	}()
+	_, err = cc.(ClientContext).StatContext(context.Background(), "first.txt")
+	require.NoError(t, err)
+}
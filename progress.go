@@ -0,0 +1,246 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// Progress describes a transfer which is underway.
+type Progress struct {
+	Path string
+
+	// Bytes is how far into the file the transfer is, which includes bytes skipped when
+	// resuming. Total is the size of the file or -1 when it isn't known.
+	Bytes int64
+	Total int64
+
+	Elapsed time.Duration
+
+	// BytesPerSecond is the rate since the previous report, which is zero when the transfer
+	// has stalled. Remaining is estimated from the average rate so far and is zero when
+	// Total isn't known.
+	BytesPerSecond float64
+	Remaining      time.Duration
+
+	// Done is set on the final report, along with Err when the transfer failed.
+	Done bool
+	Err  error
+}
+
+type progressOptions struct {
+	interval time.Duration
+	fn       func(Progress)
+}
+
+// DownloadProgress calls fn every interval (one second when zero) while Open or Reader
+// downloads a file, and once more when it's finished. For Reader the download finishes when
+// Contents are read to the end or closed. The size of the file is found with MLST or SIZE.
+//
+// fn is called from another goroutine, but never concurrently. Progress isn't reported for
+// files read with RandomAccess.
+func DownloadProgress(interval time.Duration, fn func(Progress)) ReadOption {
+	return func(o *readOptions) {
+		o.progress = &progressOptions{interval: interval, fn: fn}
+	}
+}
+
+// UploadProgress calls fn every interval (one second when zero) while UploadFile runs, and
+// once more when it's finished. The size of contents is known when they implement Stat, such
+// as an *os.File, or Len, such as a *bytes.Reader.
+//
+// fn is called from another goroutine, but never concurrently.
+func UploadProgress(interval time.Duration, fn func(Progress)) UploadOption {
+	return func(o *uploadOptions) {
+		o.progress = &progressOptions{interval: interval, fn: fn}
+	}
+}
+
+// uploadSize returns the number of bytes left in contents, or -1 when it's unknown.
+func uploadSize(contents io.Reader) int64 {
+	switch r := contents.(type) {
+	case interface{ uploadTotal() int64 }:
+		return r.uploadTotal()
+	case interface{ Stat() (fs.FileInfo, error) }:
+		if info, err := r.Stat(); err == nil && info.Mode().IsRegular() {
+			return info.Size()
+		}
+	case interface{ Len() int }:
+		return int64(r.Len())
+	}
+	return -1
+}
+
+// sizedReader keeps the size of contents which were wrapped in another reader.
+type sizedReader struct {
+	io.ReadCloser
+
+	total int64
+}
+
+func (r *sizedReader) uploadTotal() int64 {
+	return r.total
+}
+
+// fileSize returns the size of file from its metadata, or -1 when it's unknown.
+func fileSize(file *File) int64 {
+	if file.fileinfo == nil {
+		return -1
+	}
+	return file.fileinfo.Size()
+}
+
+// progressTracker reports the progress of a transfer from a goroutine until finish is called.
+type progressTracker struct {
+	path  string
+	total int64
+	fn    func(Progress)
+	start time.Time
+
+	mu       sync.Mutex
+	bytes    int64
+	previous int64 // bytes at the previous report
+	reported time.Time
+
+	stop     chan struct{}
+	stopped  chan struct{}
+	finished sync.Once
+}
+
+func startProgress(path string, total int64, opts progressOptions) *progressTracker {
+	interval := opts.interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	t := &progressTracker{
+		path:     path,
+		total:    total,
+		fn:       opts.fn,
+		start:    time.Now(),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		reported: time.Now(),
+	}
+	go func() {
+		defer close(t.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				t.fn(t.progress())
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+	return t
+}
+
+func (t *progressTracker) progress() Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	p := Progress{
+		Path:    t.path,
+		Bytes:   t.bytes,
+		Total:   t.total,
+		Elapsed: now.Sub(t.start),
+	}
+	if since := now.Sub(t.reported); since > 0 {
+		p.BytesPerSecond = float64(t.bytes-t.previous) / since.Seconds()
+	}
+	if t.total >= 0 && t.bytes > 0 && t.bytes < t.total {
+		average := float64(t.bytes) / p.Elapsed.Seconds()
+		p.Remaining = time.Duration(float64(t.total-t.bytes) / average * float64(time.Second))
+	}
+	t.previous, t.reported = t.bytes, now
+	return p
+}
+
+func (t *progressTracker) add(n int) {
+	t.mu.Lock()
+	t.bytes += int64(n)
+	t.mu.Unlock()
+}
+
+func (t *progressTracker) set(offset int64) {
+	t.mu.Lock()
+	t.bytes = offset
+	t.mu.Unlock()
+}
+
+// finish stops reporting and makes the final report.
+func (t *progressTracker) finish(err error) {
+	t.finished.Do(func() {
+		close(t.stop)
+		<-t.stopped
+
+		p := t.progress()
+		p.Done, p.Err = true, err
+		t.fn(p)
+	})
+}
+
+// reader returns r with reads counted by t. Downloads are finished once r is read to the end
+// or closed, uploads need to call finish. The result implements io.Seeker when r does.
+func (t *progressTracker) reader(r io.ReadCloser, download bool) io.ReadCloser {
+	pr := &progressReader{ReadCloser: r, tracker: t, download: download}
+	if seeker, ok := r.(io.Seeker); ok {
+		return &progressReadSeeker{progressReader: pr, seeker: seeker}
+	}
+	return pr
+}
+
+type progressReader struct {
+	io.ReadCloser
+
+	tracker  *progressTracker
+	download bool
+	readErr  error
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.tracker.add(n)
+	switch {
+	case err == io.EOF:
+		if r.download {
+			r.tracker.finish(nil)
+		}
+	case err != nil:
+		r.readErr = err
+	}
+	return n, err
+}
+
+func (r *progressReader) Close() error {
+	err := r.ReadCloser.Close()
+	if r.download {
+		r.tracker.finish(r.readErr)
+	}
+	return err
+}
+
+type progressReadSeeker struct {
+	*progressReader
+
+	seeker io.Seeker
+}
+
+func (r *progressReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	offset, err := r.seeker.Seek(offset, whence)
+	if err == nil {
+		r.tracker.set(offset)
+	}
+	return offset, err
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	var mu sync.Mutex
+	var reports []Progress
+	tracker := startProgress("/data.txt", 100, progressOptions{
+		interval: 10 * time.Millisecond,
+		fn: func(p Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			reports = append(reports, p)
+		},
+	})
+	r := tracker.reader(io.NopCloser(strings.NewReader(strings.Repeat("a", 100))), true)
+
+	buf := make([]byte, 25)
+	for range 2 {
+		_, err := io.ReadFull(r, buf)
+		require.NoError(t, err)
+		time.Sleep(30 * time.Millisecond)
+	}
+
+	// Stalled transfers are reported without any progress
+	mu.Lock()
+	last := reports[len(reports)-1]
+	mu.Unlock()
+	require.Equal(t, "/data.txt", last.Path)
+	require.Equal(t, int64(50), last.Bytes)
+	require.Equal(t, int64(100), last.Total)
+	require.Zero(t, last.BytesPerSecond)
+	require.Positive(t, last.Remaining)
+	require.False(t, last.Done)
+
+	_, err := io.Copy(io.Discard, r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	final := reports[len(reports)-1]
+	require.True(t, final.Done)
+	require.NoError(t, final.Err)
+	require.Equal(t, int64(100), final.Bytes)
+	require.Zero(t, final.Remaining)
+	require.Equal(t, 1, countDone(reports))
+}
+
+func TestProgress_Upload(t *testing.T) {
+	var reports []Progress
+	tracker := startProgress("/data.txt", 10, progressOptions{fn: func(p Progress) {
+		reports = append(reports, p)
+	}})
+	path := filepath.Join(t.TempDir(), "data.txt")
+	require.NoError(t, os.WriteFile(path, []byte("0123456789"), 0600))
+	fd, err := os.Open(path)
+	require.NoError(t, err)
+	defer fd.Close()
+	r := tracker.reader(fd, false)
+
+	// Seeking, e.g. to resume an upload, moves the progress along
+	_, err = r.(io.Seeker).Seek(4, io.SeekStart)
+	require.NoError(t, err)
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "456789", string(rest))
+	require.Empty(t, reports) // uploads finish once the server has the file
+
+	failed := errors.New("connection reset")
+	tracker.finish(failed)
+	tracker.finish(nil)
+
+	require.Len(t, reports, 1)
+	require.True(t, reports[0].Done)
+	require.Equal(t, failed, reports[0].Err)
+	require.Equal(t, int64(10), reports[0].Bytes)
+}
+
+func TestUploadSize(t *testing.T) {
+	require.Equal(t, int64(5), uploadSize(strings.NewReader("hello")))
+	require.Equal(t, int64(-1), uploadSize(io.MultiReader(strings.NewReader("hello"))))
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	require.NoError(t, os.WriteFile(path, []byte("hello, world"), 0600))
+	fd, err := os.Open(path)
+	require.NoError(t, err)
+	defer fd.Close()
+	require.Equal(t, int64(12), uploadSize(fd))
+}
+
+func countDone(reports []Progress) int {
+	var n int
+	for _, p := range reports {
+		if p.Done {
+			n++
+		}
+	}
+	return n
+}
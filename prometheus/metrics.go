@@ -0,0 +1,115 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package prometheus records the metrics of a go_ftp client with Prometheus.
+//
+//	metrics, err := prometheus.New(prom.DefaultRegisterer)
+//	...
+//	client, err := go_ftp.NewClient(go_ftp.ClientConfig{
+//		Hostname: "ftp.example.com:21",
+//		Metrics:  metrics,
+//	})
+package prometheus
+
+import (
+	"fmt"
+	"time"
+
+	go_ftp "github.com/moov-io/go-ftp"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implements go_ftp.Metrics with the following collectors, labeled by host:
+//
+//   - ftp_operation_duration_seconds: histogram of client methods by operation
+//   - ftp_operation_errors_total: failed client methods by operation
+//   - ftp_transferred_bytes_total: bytes transferred by direction (upload or download)
+//   - ftp_connect_duration_seconds: histogram of connecting and logging in to the server
+//   - ftp_connect_errors_total: failed connections
+//   - ftp_reconnects_total: idle connections which failed a health check and were replaced
+type Metrics struct {
+	operations      *prom.HistogramVec
+	operationErrors *prom.CounterVec
+	bytes           *prom.CounterVec
+	connects        *prom.HistogramVec
+	connectErrors   *prom.CounterVec
+	reconnects      *prom.CounterVec
+}
+
+var _ go_ftp.Metrics = (&Metrics{})
+
+// New returns Metrics with its collectors registered with reg, or prometheus.DefaultRegisterer
+// when reg is nil.
+func New(reg prom.Registerer) (*Metrics, error) {
+	if reg == nil {
+		reg = prom.DefaultRegisterer
+	}
+
+	m := &Metrics{
+		operations: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: "ftp",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of FTP client operations",
+			Buckets:   prom.ExponentialBuckets(0.005, 4, 8),
+		}, []string{"host", "operation"}),
+		operationErrors: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: "ftp",
+			Name:      "operation_errors_total",
+			Help:      "FTP client operations which failed",
+		}, []string{"host", "operation"}),
+		bytes: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: "ftp",
+			Name:      "transferred_bytes_total",
+			Help:      "Bytes uploaded and downloaded",
+		}, []string{"host", "direction"}),
+		connects: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: "ftp",
+			Name:      "connect_duration_seconds",
+			Help:      "Duration of connecting and logging in to FTP servers",
+			Buckets:   prom.DefBuckets,
+		}, []string{"host"}),
+		connectErrors: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: "ftp",
+			Name:      "connect_errors_total",
+			Help:      "Connections to FTP servers which failed",
+		}, []string{"host"}),
+		reconnects: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: "ftp",
+			Name:      "reconnects_total",
+			Help:      "Idle FTP connections which failed a health check and were replaced",
+		}, []string{"host"}),
+	}
+
+	for _, c := range []prom.Collector{m.operations, m.operationErrors, m.bytes, m.connects, m.connectErrors, m.reconnects} {
+		if err := reg.Register(c); err != nil {
+			return nil, fmt.Errorf("registering FTP metrics: %w", err)
+		}
+	}
+	return m, nil
+}
+
+func (m *Metrics) ObserveOperation(host, op string, duration time.Duration, err error) {
+	m.operations.WithLabelValues(host, op).Observe(duration.Seconds())
+	if err != nil {
+		m.operationErrors.WithLabelValues(host, op).Inc()
+	}
+}
+
+func (m *Metrics) AddBytes(host, direction string, n int64) {
+	if n > 0 {
+		m.bytes.WithLabelValues(host, direction).Add(float64(n))
+	}
+}
+
+func (m *Metrics) ObserveConnect(host string, duration time.Duration, err error) {
+	m.connects.WithLabelValues(host).Observe(duration.Seconds())
+	if err != nil {
+		m.connectErrors.WithLabelValues(host).Inc()
+	}
+}
+
+func (m *Metrics) IncReconnects(host string) {
+	m.reconnects.WithLabelValues(host).Inc()
+}
@@ -0,0 +1,51 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package prometheus
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prom.NewRegistry()
+	m, err := New(reg)
+	require.NoError(t, err)
+
+	m.ObserveOperation("ftp.example.com:21", "open", 20*time.Millisecond, nil)
+	m.ObserveOperation("ftp.example.com:21", "open", time.Second, errors.New("550 not found"))
+	m.AddBytes("ftp.example.com:21", "download", 1024)
+	m.AddBytes("ftp.example.com:21", "download", 0)
+	m.AddBytes("ftp.example.com:21", "upload", 10)
+	m.ObserveConnect("ftp.example.com:21", 50*time.Millisecond, nil)
+	m.IncReconnects("ftp.example.com:21")
+
+	require.Equal(t, 1024.0, testutil.ToFloat64(m.bytes.WithLabelValues("ftp.example.com:21", "download")))
+	require.Equal(t, 10.0, testutil.ToFloat64(m.bytes.WithLabelValues("ftp.example.com:21", "upload")))
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP ftp_operation_errors_total FTP client operations which failed
+# TYPE ftp_operation_errors_total counter
+ftp_operation_errors_total{host="ftp.example.com:21",operation="open"} 1
+# HELP ftp_reconnects_total Idle FTP connections which failed a health check and were replaced
+# TYPE ftp_reconnects_total counter
+ftp_reconnects_total{host="ftp.example.com:21"} 1
+`), "ftp_operation_errors_total", "ftp_reconnects_total")
+	require.NoError(t, err)
+
+	count, err := testutil.GatherAndCount(reg, "ftp_operation_duration_seconds", "ftp_connect_duration_seconds", "ftp_connect_errors_total")
+	require.NoError(t, err)
+	require.Equal(t, 2, count) // the connect errors counter has no series yet
+
+	// Registering twice fails
+	_, err = New(reg)
+	require.ErrorContains(t, err, "registering FTP metrics")
+}
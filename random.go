@@ -0,0 +1,231 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// RandomAccessConfig sets how files opened with RandomAccess are downloaded.
+type RandomAccessConfig struct {
+	// BlockSize is how many bytes are downloaded at a time. The default is 64KiB.
+	BlockSize int
+
+	// CacheBlocks is how many of the most recently used blocks are kept in memory.
+	// The default is 16.
+	CacheBlocks int
+}
+
+// RandomAccess makes Reader return a File whose contents are downloaded on demand, so the File
+// supports io.ReaderAt and io.Seeker. Each block which is read is downloaded from its offset with
+// REST and RETR, which allows reading the end of a large file without downloading all of it.
+//
+// The server must support the REST command and report the file's size.
+func RandomAccess(cfg RandomAccessConfig) ReadOption {
+	return func(o *readOptions) {
+		o.randomAccess = &cfg
+	}
+}
+
+// randomReader reads a remote file through a cache of blocks which are downloaded when needed.
+// It's safe for concurrent use, but blocks are downloaded one at a time.
+//
+// Read streams the file from the current offset instead, so reading sequentially only needs
+// one download until Seek moves the offset.
+type randomReader struct {
+	open    func(offset int64) (io.ReadCloser, error)
+	size    int64
+	retries int
+
+	blockSize   int64
+	cacheBlocks int
+
+	mu     sync.Mutex
+	cache  map[int64][]byte
+	recent []int64 // block numbers, most recently used last
+	offset int64
+	closed bool
+
+	stream       io.ReadCloser
+	streamOffset int64
+}
+
+func newRandomReader(size int64, cfg RandomAccessConfig, retries int, open func(offset int64) (io.ReadCloser, error)) *randomReader {
+	return &randomReader{
+		open:        open,
+		size:        size,
+		retries:     retries,
+		blockSize:   int64(cmp.Or(cfg.BlockSize, 64*1024)),
+		cacheBlocks: cmp.Or(cfg.CacheBlocks, 16),
+		cache:       make(map[int64][]byte),
+	}
+}
+
+var errReaderClosed = errors.New("read of closed file")
+
+func (r *randomReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.readAt(p, off)
+}
+
+func (r *randomReader) readAt(p []byte, off int64) (int, error) {
+	if r.closed {
+		return 0, errReaderClosed
+	}
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	var n int
+	for n < len(p) && off < r.size {
+		block, err := r.block(off / r.blockSize)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], block[off%r.blockSize:])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *randomReader) block(num int64) ([]byte, error) {
+	if block, ok := r.cache[num]; ok {
+		r.recent = append(slices.DeleteFunc(r.recent, func(n int64) bool { return n == num }), num)
+		return block, nil
+	}
+
+	block, err := r.download(num * r.blockSize)
+	for attempt := 0; err != nil && attempt < r.retries; attempt++ {
+		block, err = r.download(num * r.blockSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading block at byte %d: %w", num*r.blockSize, err)
+	}
+
+	if len(r.recent) >= r.cacheBlocks {
+		delete(r.cache, r.recent[0])
+		r.recent = r.recent[1:]
+	}
+	r.cache[num] = block
+	r.recent = append(r.recent, num)
+
+	return block, nil
+}
+
+func (r *randomReader) download(offset int64) ([]byte, error) {
+	// Give up the stream's connection as there might not be another one
+	r.closeStream()
+
+	rc, err := r.open(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, min(r.blockSize, r.size-offset))
+	n, err := io.ReadFull(rc, buf)
+
+	// Stopping the transfer early is reported by some servers, which we don't mind
+	rc.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("read %d of %d bytes: %w", n, len(buf), err)
+	}
+	return buf, nil
+}
+
+func (r *randomReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, errReaderClosed
+	}
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.stream == nil || r.streamOffset != r.offset {
+		r.closeStream()
+
+		stream, err := r.open(r.offset)
+		if err != nil {
+			return 0, fmt.Errorf("reading from byte %d: %w", r.offset, err)
+		}
+		r.stream, r.streamOffset = stream, r.offset
+	}
+
+	n, err := r.stream.Read(p)
+	r.offset += int64(n)
+	r.streamOffset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *randomReader) closeStream() {
+	if r.stream != nil {
+		r.stream.Close()
+		r.stream = nil
+	}
+}
+
+func (r *randomReader) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *randomReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	clear(r.cache)
+	r.recent = nil
+
+	if r.stream != nil {
+		err := r.stream.Close()
+		r.stream = nil
+		return err
+	}
+	return nil
+}
+
+// bytesReadCloser is a bytes.Reader with a Close method, which keeps io.ReaderAt and
+// io.Seeker available for files downloaded into memory.
+type bytesReadCloser struct {
+	*bytes.Reader
+}
+
+func (bytesReadCloser) Close() error {
+	return nil
+}
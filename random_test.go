@@ -0,0 +1,87 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandomReader(t *testing.T) {
+	const contents = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+	var offsets []int64
+	open := func(offset int64) (io.ReadCloser, error) {
+		offsets = append(offsets, offset)
+		return io.NopCloser(strings.NewReader(contents[offset:])), nil
+	}
+	r := newRandomReader(int64(len(contents)), RandomAccessConfig{BlockSize: 10, CacheBlocks: 2}, 0, open)
+
+	buf := make([]byte, 5)
+	n, err := r.ReadAt(buf, 32)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, "wxyz", string(buf[:n]))
+	require.Equal(t, []int64{30}, offsets)
+
+	// Read across blocks
+	n, err = r.ReadAt(buf, 8)
+	require.NoError(t, err)
+	require.Equal(t, "89abc", string(buf[:n]))
+	require.Equal(t, []int64{30, 0, 10}, offsets)
+
+	// Cached blocks aren't downloaded again, the least recently used are evicted
+	n, err = r.ReadAt(buf, 12)
+	require.NoError(t, err)
+	require.Equal(t, "cdefg", string(buf[:n]))
+	require.Equal(t, []int64{30, 0, 10}, offsets)
+
+	n, err = r.ReadAt(buf, 30)
+	require.NoError(t, err)
+	require.Equal(t, "uvwxy", string(buf[:n]))
+	require.Equal(t, []int64{30, 0, 10, 30}, offsets)
+
+	// Seek and Read
+	pos, err := r.Seek(-6, io.SeekEnd)
+	require.NoError(t, err)
+	require.Equal(t, int64(30), pos)
+
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "uvwxyz", string(rest))
+
+	_, err = r.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	require.NoError(t, iotest.TestReader(r, []byte(contents)))
+
+	require.NoError(t, r.Close())
+	_, err = r.ReadAt(buf, 0)
+	require.ErrorIs(t, err, errReaderClosed)
+}
+
+func TestRandomReader_Retries(t *testing.T) {
+	var attempts int
+	open := func(offset int64) (io.ReadCloser, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("connection reset")
+		}
+		return io.NopCloser(strings.NewReader("hello")), nil
+	}
+
+	r := newRandomReader(5, RandomAccessConfig{}, 1, open)
+	_, err := r.ReadAt(make([]byte, 5), 0)
+	require.ErrorContains(t, err, "reading block at byte 0: connection reset")
+
+	r = newRandomReader(5, RandomAccessConfig{}, 1, open)
+	buf := make([]byte, 5)
+	_, err = r.ReadAt(buf, 0)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+}
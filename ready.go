@@ -0,0 +1,135 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// ReadyOptions decides when a file found by Watch is done being uploaded. Files are ready once
+// their size and modification time are unchanged for Polls polls and QuietPeriod, or as soon as
+// a trigger file appears. When Polls, QuietPeriod and TriggerSuffixes are all empty, files are
+// ready after one poll without changes.
+//
+// A file which changes after it was ready is reported as ready again once it settles.
+type ReadyOptions struct {
+	// Polls is how many polls in a row must find a file unchanged after it was first seen.
+	Polls int
+
+	// QuietPeriod is how long a file must be unchanged for, as seen by polls.
+	QuietPeriod time.Duration
+
+	// TriggerSuffixes are suffixes of companion files which mark a file as ready, such as
+	// ".done" or ".ok". A.csv is ready once A.csv.done or A.done exists. Trigger files are
+	// never reported as ready themselves. A suffix without a leading dot, such as "ok", only
+	// marks a file as a trigger when the file it's paired with exists, so book isn't mistaken
+	// for the trigger of bo.
+	TriggerSuffixes []string
+}
+
+// readyTracker follows how long files have been unchanged across polls.
+type readyTracker struct {
+	opts  ReadyOptions
+	files map[string]*readyState
+}
+
+type readyState struct {
+	file  FileState
+	polls int       // unchanged polls since the file was seen with its current state
+	since time.Time // when the file was first seen with its current state
+}
+
+// newReadyTracker returns a tracker which starts from the files of a saved snapshot. Files
+// which weren't ready need to settle again, as it isn't known how long they were unchanged.
+func newReadyTracker(opts ReadyOptions, previous map[string]FileState) *readyTracker {
+	if opts.Polls <= 0 && opts.QuietPeriod <= 0 && len(opts.TriggerSuffixes) == 0 {
+		opts.Polls = 1
+	}
+	t := &readyTracker{opts: opts, files: make(map[string]*readyState)}
+	for name, file := range previous {
+		if file.Ready {
+			t.files[name] = &readyState{file: file}
+		}
+	}
+	return t
+}
+
+// update compares the files of a poll taken at now and returns FileReady events for files
+// which became ready. Ready is set on the files of current which are ready.
+func (t *readyTracker) update(current map[string]FileState, now time.Time) []WatchEvent {
+	var events []WatchEvent
+	for name, file := range current {
+		state, seen := t.files[name]
+		if !seen || state.file.Size != file.Size || !state.file.ModTime.Equal(file.ModTime) {
+			state = &readyState{file: file, since: now}
+			t.files[name] = state
+		} else {
+			state.polls++
+		}
+
+		if !state.file.Ready && !t.isTrigger(name, current) && (t.triggered(name, current) || t.settled(state, now)) {
+			state.file.Ready = true
+			events = append(events, WatchEvent{Op: FileReady, Path: name, File: state.file})
+		}
+		current[name] = state.file
+	}
+	for name := range t.files {
+		if _, exists := current[name]; !exists {
+			delete(t.files, name)
+		}
+	}
+	return events
+}
+
+func (t *readyTracker) settled(state *readyState, now time.Time) bool {
+	if t.opts.Polls <= 0 && t.opts.QuietPeriod <= 0 {
+		return false // only trigger files are used
+	}
+	return state.polls >= t.opts.Polls && now.Sub(state.since) >= t.opts.QuietPeriod
+}
+
+// isTrigger reports whether name is a trigger file. Suffixes which don't start with a dot only
+// match names paired with a file in current, so the suffix "ok" doesn't make book a trigger
+// unless bo or bo.csv exists.
+func (t *readyTracker) isTrigger(name string, current map[string]FileState) bool {
+	for _, suffix := range t.opts.TriggerSuffixes {
+		target, found := strings.CutSuffix(name, suffix)
+		if !found || target == "" {
+			continue
+		}
+		if strings.HasPrefix(suffix, ".") || paired(name, target, current) {
+			return true
+		}
+	}
+	return false
+}
+
+// paired reports whether a file other than trigger is named target, or target plus an extension.
+func paired(trigger, target string, current map[string]FileState) bool {
+	if _, ok := current[target]; ok {
+		return true
+	}
+	for name := range current {
+		if name != trigger && strings.TrimSuffix(name, path.Ext(name)) == target {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *readyTracker) triggered(name string, current map[string]FileState) bool {
+	base := strings.TrimSuffix(name, path.Ext(name))
+	for _, suffix := range t.opts.TriggerSuffixes {
+		if _, ok := current[name+suffix]; ok {
+			return true
+		}
+		if _, ok := current[base+suffix]; ok {
+			return true
+		}
+	}
+	return false
+}
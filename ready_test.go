@@ -0,0 +1,136 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"context"
+	"io"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadyTracker(t *testing.T) {
+	now := time.Now()
+	poll := func(tracker *readyTracker, files map[string]FileState, at time.Duration) []string {
+		var ready []string
+		for _, event := range tracker.update(maps.Clone(files), now.Add(at)) {
+			require.Equal(t, FileReady, event.Op)
+			require.True(t, event.File.Ready)
+			ready = append(ready, event.Path)
+		}
+		return ready
+	}
+
+	t.Run("polls", func(t *testing.T) {
+		tracker := newReadyTracker(ReadyOptions{Polls: 2}, nil)
+		files := map[string]FileState{"/a.csv": {Size: 10, ModTime: now}}
+
+		require.Empty(t, poll(tracker, files, 0))
+		require.Empty(t, poll(tracker, files, time.Second))
+
+		// Still uploading
+		files["/a.csv"] = FileState{Size: 20, ModTime: now.Add(time.Second)}
+		require.Empty(t, poll(tracker, files, 2*time.Second))
+		require.Empty(t, poll(tracker, files, 3*time.Second))
+		require.Equal(t, []string{"/a.csv"}, poll(tracker, files, 4*time.Second))
+		require.Empty(t, poll(tracker, files, 5*time.Second))
+	})
+
+	t.Run("quiet period", func(t *testing.T) {
+		tracker := newReadyTracker(ReadyOptions{QuietPeriod: time.Minute}, nil)
+		files := map[string]FileState{"/a.csv": {Size: 10, ModTime: now}}
+
+		require.Empty(t, poll(tracker, files, 0))
+		require.Empty(t, poll(tracker, files, 30*time.Second))
+		require.Equal(t, []string{"/a.csv"}, poll(tracker, files, time.Minute))
+	})
+
+	t.Run("default", func(t *testing.T) {
+		tracker := newReadyTracker(ReadyOptions{}, nil)
+		files := map[string]FileState{"/a.csv": {Size: 10, ModTime: now}}
+
+		require.Empty(t, poll(tracker, files, 0))
+		require.Equal(t, []string{"/a.csv"}, poll(tracker, files, time.Second))
+	})
+
+	t.Run("triggers", func(t *testing.T) {
+		tracker := newReadyTracker(ReadyOptions{TriggerSuffixes: []string{".done", ".ok"}}, nil)
+		files := map[string]FileState{
+			"/a.csv": {Size: 10, ModTime: now},
+			"/b.csv": {Size: 10, ModTime: now},
+			"/c.csv": {Size: 10, ModTime: now},
+		}
+		require.Empty(t, poll(tracker, files, 0))
+		require.Empty(t, poll(tracker, files, time.Hour)) // stability isn't used
+
+		files["/a.csv.done"] = FileState{ModTime: now}
+		files["/b.ok"] = FileState{ModTime: now}
+		require.ElementsMatch(t, []string{"/a.csv", "/b.csv"}, poll(tracker, files, 2*time.Hour))
+	})
+
+	t.Run("triggers without a dot", func(t *testing.T) {
+		tracker := newReadyTracker(ReadyOptions{Polls: 1, TriggerSuffixes: []string{"ok"}}, nil)
+		files := map[string]FileState{
+			"/book":  {Size: 10, ModTime: now},
+			"/ebook": {Size: 10, ModTime: now},
+			"/bo":    {Size: 10, ModTime: now},
+		}
+		// book is the trigger of bo, ebook isn't paired with any file
+		require.Equal(t, []string{"/bo"}, poll(tracker, files, 0))
+		require.Equal(t, []string{"/ebook"}, poll(tracker, files, time.Second))
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		// Ready files of a saved snapshot aren't reported again
+		saved := map[string]FileState{
+			"/a.csv": {Size: 10, ModTime: now, Ready: true},
+			"/b.csv": {Size: 10, ModTime: now},
+		}
+		tracker := newReadyTracker(ReadyOptions{Polls: 1}, saved)
+		files := map[string]FileState{
+			"/a.csv": {Size: 10, ModTime: now},
+			"/b.csv": {Size: 10, ModTime: now},
+		}
+		require.Empty(t, poll(tracker, files, 0))
+		require.Equal(t, []string{"/b.csv"}, poll(tracker, files, time.Second))
+	})
+}
+
+func TestWatch_Ready(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := NewMockClient(t)
+	upload := func(path, contents string) {
+		t.Helper()
+		require.NoError(t, client.UploadFile(path, io.NopCloser(strings.NewReader(contents))))
+	}
+	upload("/a.csv", "a,b,c")
+
+	events, err := Watch(ctx, client, "/", WatchOptions{
+		Interval: 10 * time.Millisecond,
+		Ready:    &ReadyOptions{TriggerSuffixes: []string{".done"}},
+	})
+	require.NoError(t, err)
+
+	event := <-events
+	require.Equal(t, FileCreated, event.Op)
+	require.Equal(t, "/a.csv", event.Path)
+
+	// Events are sorted by path
+	upload("/a.csv.done", "")
+	event = <-events
+	require.Equal(t, FileReady, event.Op)
+	require.Equal(t, "/a.csv", event.Path)
+	require.Equal(t, int64(5), event.File.Size)
+
+	event = <-events
+	require.Equal(t, FileCreated, event.Op)
+	require.Equal(t, "/a.csv.done", event.Path)
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/textproto"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy retries operations which fail with a transient error, such as a 421 reply or a
+// dropped connection. Each attempt checks out a connection from the pool, so broken connections
+// are replaced before trying again.
+//
+// Retries apply to Open, Reader (until the download starts), Stat, Delete, ReadDir, ListFiles
+// and Walk (until the first entry is visited). Uploads are retried when RetryUploads is set.
+type RetryPolicy struct {
+	// MaxAttempts is how many times an operation is tried, including the first attempt.
+	// Retries are disabled when it's zero or one.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry, which doubles after each attempt up
+	// to MaxBackoff. A random jitter of up to half the delay is subtracted so clients don't
+	// retry in lockstep. The defaults are 100ms and 10s.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// RetryUploads retries UploadFile when contents implement io.Seeker, such as an *os.File.
+	// Contents are rewound to where the failed attempt started before uploading again.
+	RetryUploads bool
+
+	// Retryable reports if an operation should be tried again after err.
+	// IsRetryable is used when nil.
+	Retryable func(err error) bool
+}
+
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	limit := p.MaxBackoff
+	if limit <= 0 {
+		limit = 10 * time.Second
+	}
+
+	delay := initial
+	for i := 0; i < retry && delay < limit; i++ {
+		delay *= 2
+	}
+	delay = min(delay, limit)
+	return delay - rand.N(delay/2+1)
+}
+
+// IsRetryable reports whether err is likely to be transient. Replies with a 4xx code (such as
+// 421 service not available, 425 can't open data connection or 426 transfer aborted), timeouts,
+// and reset, refused or dropped connections are retryable. Other replies, context errors and
+// connections to addresses which are not allowed are not.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, errAborted) {
+		return false
+	}
+
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		return tpErr.Code >= 400 && tpErr.Code < 500
+	}
+	var ipErr *IPNotAllowedError
+	if errors.As(err, &ipErr) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	for _, target := range []error{io.EOF, io.ErrUnexpectedEOF, net.ErrClosed, syscall.ECONNRESET, syscall.ECONNREFUSED, syscall.EPIPE} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// noRetryError stops retry from trying an operation again, e.g. once it had side effects.
+type noRetryError struct {
+	err error
+}
+
+func (e *noRetryError) Error() string {
+	return e.err.Error()
+}
+
+// retry calls op until it succeeds or fails with an error which isn't retryable, the attempts
+// of the client's RetryPolicy are used up or ctx is done.
+func (cc *client) retry(ctx context.Context, op func() error) error {
+	policy := cc.cfg.Retry
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil {
+			return nil
+		}
+		if stop, ok := err.(*noRetryError); ok {
+			return stop.err
+		}
+		if attempt >= policy.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		delay := policy.backoff(attempt - 1)
+		cc.logger().Warn("ftp retrying", slog.Int("attempt", attempt), slog.Duration("backoff", delay), slog.Any("error", err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", context.Cause(ctx), err)
+		}
+	}
+}
+
+// sleep waits for d, or returns the cause of ctx being done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
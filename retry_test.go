@@ -0,0 +1,216 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/netip"
+	"net/textproto"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err       error
+		retryable bool
+	}{
+		{&textproto.Error{Code: 421, Msg: "Service not available"}, true},
+		{newPathError("open", "/a.txt", &textproto.Error{Code: 425, Msg: "Can't open data connection"}), true},
+		{fmt.Errorf("stor: %w", &textproto.Error{Code: 451, Msg: "Local error"}), true},
+		{&textproto.Error{Code: 550, Msg: "No such file"}, false},
+		{&textproto.Error{Code: 530, Msg: "Not logged in"}, false},
+		{&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: &IPNotAllowedError{IP: netip.MustParseAddr("10.0.0.1")}}, false},
+		{fmt.Errorf("reading: %w", io.ErrUnexpectedEOF), true},
+		{context.Canceled, false},
+		{fmt.Errorf("%w: read tcp: i/o timeout", context.DeadlineExceeded), false},
+		{errors.New("invalid path"), false},
+		{nil, false},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.retryable, IsRetryable(tc.err), "%v", tc.err)
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	for retry, expected := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for range 10 {
+			delay := policy.backoff(retry)
+			require.LessOrEqual(t, delay, expected)
+			require.GreaterOrEqual(t, delay, expected/2)
+		}
+	}
+}
+
+func TestClient_retry(t *testing.T) {
+	cc := &client{cfg: ClientConfig{
+		Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	}}
+	ctx := context.Background()
+	transient := &textproto.Error{Code: 421, Msg: "Service not available"}
+
+	var attempts int
+	err := cc.retry(ctx, func() error {
+		attempts++
+		return transient
+	})
+	require.ErrorIs(t, err, transient)
+	require.Equal(t, 3, attempts)
+
+	// Errors which aren't transient are returned straight away
+	attempts = 0
+	err = cc.retry(ctx, func() error {
+		attempts++
+		if attempts == 1 {
+			return transient
+		}
+		return &noRetryError{err: io.ErrClosedPipe}
+	})
+	require.Equal(t, io.ErrClosedPipe, err)
+	require.Equal(t, 2, attempts)
+
+	// Waiting is stopped by ctx
+	cc.cfg.Retry.InitialBackoff = time.Minute
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	attempts = 0
+	err = cc.retry(ctx, func() error {
+		attempts++
+		return transient
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorIs(t, err, transient)
+	require.Equal(t, 1, attempts)
+
+	// Retries are disabled by default
+	cc.cfg.Retry = RetryPolicy{}
+	attempts = 0
+	err = cc.retry(context.Background(), func() error {
+		attempts++
+		return transient
+	})
+	require.ErrorIs(t, err, transient)
+	require.Equal(t, 1, attempts)
+}
+
+// flakyReader fails once after reading half of its contents, like a dropped connection.
+type flakyReader struct {
+	r      *strings.Reader
+	failed bool
+}
+
+func (r *flakyReader) Read(p []byte) (int, error) {
+	if !r.failed && r.r.Len() <= int(r.r.Size())/2 {
+		r.failed = true
+		return 0, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	}
+	return r.r.Read(p[:min(len(p), 1024)])
+}
+
+func (r *flakyReader) Seek(offset int64, whence int) (int64, error) {
+	return r.r.Seek(offset, whence)
+}
+
+func (r *flakyReader) Close() error {
+	return nil
+}
+
+func TestClient_Retry(t *testing.T) {
+	cc, err := NewClient(ClientConfig{
+		Hostname: "127.0.0.1:2121",
+		Username: "admin",
+		Password: "123456",
+		Retry: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			RetryUploads:   true,
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { cc.Close() })
+
+	// Refuse connections until failures runs out
+	p := cc.(*client).pool
+	var mu sync.Mutex
+	var dials, failures int
+	dial := p.dial
+	p.dial = func(ctx context.Context) (*serverConn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		dials++
+		if failures > 0 {
+			failures--
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+		}
+		return dial(ctx)
+	}
+	failAfterClose := func(n int) {
+		t.Helper()
+		require.NoError(t, p.close())
+		mu.Lock()
+		dials, failures = 0, n
+		mu.Unlock()
+	}
+
+	failAfterClose(2)
+	info, err := cc.Stat("/first.txt")
+	require.NoError(t, err)
+	require.Equal(t, "first.txt", info.Name())
+	require.Equal(t, 3, dials)
+
+	failAfterClose(3)
+	_, err = cc.ReadDir("/archive")
+	require.ErrorIs(t, err, syscall.ECONNREFUSED)
+	require.Equal(t, 3, dials)
+
+	failAfterClose(1)
+	file, err := cc.Open("/archive/old.txt")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	failAfterClose(1)
+	var walked []string
+	err = cc.Walk("/archive", func(path string, d fs.DirEntry, err error) error {
+		walked = append(walked, path)
+		return err
+	})
+	require.NoError(t, err)
+	require.Contains(t, walked, "/archive/old.txt")
+
+	// Missing files aren't retried
+	failAfterClose(0)
+	_, err = cc.Stat("/missing.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.Equal(t, 1, dials)
+
+	t.Run("upload", func(t *testing.T) {
+		contents := strings.Repeat("0123456789", 1000)
+		contentsReader := &flakyReader{r: strings.NewReader(contents)}
+		err := cc.UploadFile("/retried.txt", contentsReader)
+		require.NoError(t, err)
+		require.True(t, contentsReader.failed)
+		t.Cleanup(func() { cc.Delete("/retried.txt") })
+
+		file, err := cc.Open("/retried.txt")
+		require.NoError(t, err)
+		bs, _ := io.ReadAll(file)
+		require.Equal(t, contents, string(bs))
+	})
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit limits how fast files are transferred over data connections.
+type RateLimit struct {
+	// BytesPerSecond is the sustained transfer rate. Transfers are not limited when it's zero.
+	BytesPerSecond int
+
+	// Burst is how many bytes can be transferred at once after being idle, which defaults to
+	// BytesPerSecond.
+	Burst int
+}
+
+func (l RateLimit) limiter() *rate.Limiter {
+	if l.BytesPerSecond <= 0 {
+		return nil
+	}
+	burst := l.Burst
+	if burst <= 0 {
+		burst = l.BytesPerSecond
+	}
+	return rate.NewLimiter(rate.Limit(l.BytesPerSecond), burst)
+}
+
+// DownloadRateLimit limits the transfer rate of Open and Reader to limit instead of
+// ClientConfig.RateLimit. A zero RateLimit downloads without a limit.
+func DownloadRateLimit(limit RateLimit) ReadOption {
+	return func(o *readOptions) {
+		o.rateLimit = &limit
+	}
+}
+
+// UploadRateLimit limits the transfer rate of UploadFile, AppendFile, Create and OpenFile to
+// limit instead of ClientConfig.RateLimit. A zero RateLimit uploads without a limit.
+func UploadRateLimit(limit RateLimit) UploadOption {
+	return func(o *uploadOptions) {
+		o.rateLimit = &limit
+	}
+}
+
+// limiter returns the limiter for a transfer, which has its own when override is set and
+// otherwise shares the client's. It's nil when the transfer isn't limited.
+func (cc *client) limiter(override *RateLimit) *rate.Limiter {
+	if override != nil {
+		return override.limiter()
+	}
+	return cc.rateLimiter
+}
+
+// throttle returns r with reads slowed down to the rate of limiter. Uploads wait before the
+// bytes read are written to the data connection and downloads wait before reading more,
+// which leaves the server waiting on TCP flow control. The result implements io.Seeker
+// when r does.
+func throttle(ctx context.Context, r io.Reader, limiter *rate.Limiter) io.Reader {
+	if limiter == nil {
+		return r
+	}
+	tr := &throttledReader{ctx: ctx, r: r, limiter: limiter}
+	if seeker, ok := r.(io.Seeker); ok {
+		return &throttledReadSeeker{throttledReader: tr, Seeker: seeker}
+	}
+	return tr
+}
+
+// throttleCloser is throttle for an io.ReadCloser.
+func throttleCloser(ctx context.Context, rc io.ReadCloser, limiter *rate.Limiter) io.ReadCloser {
+	if limiter == nil {
+		return rc
+	}
+	r := throttle(ctx, rc, limiter)
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		return &throttledReadSeekCloser{ReadSeeker: seeker, Closer: rc}
+	}
+	return &throttledReadCloser{Reader: r, Closer: rc}
+}
+
+type throttledReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	// WaitN fails for more than the burst
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+type throttledReadSeeker struct {
+	*throttledReader
+	io.Seeker
+}
+
+type throttledReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type throttledReadSeekCloser struct {
+	io.ReadSeeker
+	io.Closer
+}
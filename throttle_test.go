@@ -0,0 +1,49 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	require.Nil(t, RateLimit{}.limiter())
+
+	limiter := RateLimit{BytesPerSecond: 1000}.limiter()
+	require.Equal(t, 1000, limiter.Burst())
+
+	limiter = RateLimit{BytesPerSecond: 1000, Burst: 100}.limiter()
+	require.Equal(t, 100, limiter.Burst())
+}
+
+func TestThrottle(t *testing.T) {
+	ctx := context.Background()
+
+	r := strings.NewReader(strings.Repeat("a", 300))
+	require.Same(t, r, throttle(ctx, r, nil))
+
+	// The burst is free, the remaining 200 bytes take 200ms
+	start := time.Now()
+	throttled := throttle(ctx, r, RateLimit{BytesPerSecond: 1000, Burst: 100}.limiter())
+	bs, err := io.ReadAll(throttled)
+	require.NoError(t, err)
+	require.Len(t, bs, 300)
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	// Seeking is kept for resumed and retried uploads
+	_, err = throttled.(io.Seeker).Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = io.ReadAll(throttle(ctx, r, RateLimit{BytesPerSecond: 1}.limiter()))
+	require.ErrorIs(t, err, context.Canceled)
+}
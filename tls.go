@@ -0,0 +1,202 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// TLSMode sets if and how connections to the FTP server are protected with TLS.
+type TLSMode string
+
+const (
+	// TLSModeNone uses plaintext FTP.
+	TLSModeNone TLSMode = "none"
+
+	// TLSModeExplicit connects in plaintext and upgrades the connection with AUTH TLS before
+	// logging in. This is also known as FTPES and usually runs on port 21.
+	TLSModeExplicit TLSMode = "explicit"
+
+	// TLSModeImplicit negotiates TLS as soon as the connection is established. This is also
+	// known as FTPS and usually runs on port 990.
+	TLSModeImplicit TLSMode = "implicit"
+)
+
+// tlsConfig returns the TLSMode to connect with along with a tls.Config for data and control
+// connections. The config is nil for TLSModeNone.
+//
+// Providing TLS settings (such as CAFile or TLSConfig) enables implicit TLS, like previous versions did
+// when CAFile was set. An error is returned instead of connecting in plaintext when TLS settings
+// are provided with TLSModeNone.
+func tlsConfig(cfg ClientConfig) (TLSMode, *tls.Config, error) {
+	mode := cfg.TLSMode
+	hasSettings := cfg.CAFile != "" || cfg.TLSConfig != nil || cfg.ClientCertFile != "" ||
+		len(cfg.PinnedPublicKeys) > 0 || len(cfg.PinnedCertificates) > 0
+
+	switch mode {
+	case "":
+		mode = TLSModeNone
+		if hasSettings {
+			mode = TLSModeImplicit
+		}
+	case TLSModeNone:
+		if hasSettings {
+			return mode, nil, errors.New("tlsConfig: TLS settings provided with TLSMode none")
+		}
+	case TLSModeExplicit, TLSModeImplicit:
+	default:
+		return mode, nil, fmt.Errorf("tlsConfig: unknown TLSMode %q", mode)
+	}
+	if mode == TLSModeNone {
+		return mode, nil, nil
+	}
+
+	var conf *tls.Config
+	if cfg.TLSConfig != nil {
+		conf = cfg.TLSConfig.Clone()
+	} else {
+		conf = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	if cfg.CAFile != "" {
+		bs, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return mode, nil, fmt.Errorf("tlsConfig: failed to read %s: %v", cfg.CAFile, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if pool == nil || err != nil {
+			pool = x509.NewCertPool()
+		}
+		ok := pool.AppendCertsFromPEM(bs)
+		if !ok {
+			return mode, nil, fmt.Errorf("tlsConfig: problem with AppendCertsFromPEM from %s", cfg.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if cfg.ClientCertFile != "" {
+		cert, err := clientCertificate(cfg)
+		if err != nil {
+			return mode, nil, fmt.Errorf("tlsConfig: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(cfg.PinnedPublicKeys) > 0 || len(cfg.PinnedCertificates) > 0 {
+		verifyPins, err := pinVerifier(cfg.PinnedPublicKeys, cfg.PinnedCertificates)
+		if err != nil {
+			return mode, nil, fmt.Errorf("tlsConfig: %w", err)
+		}
+		verify := conf.VerifyConnection
+		conf.VerifyConnection = func(cs tls.ConnectionState) error {
+			if verify != nil {
+				if err := verify(cs); err != nil {
+					return err
+				}
+			}
+			return verifyPins(cs)
+		}
+	}
+
+	// Data connections are dialed by IP address, so verify them against the server's hostname
+	if conf.ServerName == "" {
+		host, _, _ := net.SplitHostPort(address(cfg.Hostname, mode))
+		conf.ServerName = host
+	}
+
+	// Many servers require data connections to resume the control connection's TLS session
+	if conf.ClientSessionCache == nil {
+		conf.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	return mode, conf, nil
+}
+
+// address returns hostname with the default port of mode added when hostname has no port.
+func address(hostname string, mode TLSMode) string {
+	if _, _, err := net.SplitHostPort(hostname); err == nil {
+		return hostname
+	}
+	port := "21"
+	if mode == TLSModeImplicit {
+		port = "990"
+	}
+	return net.JoinHostPort(strings.Trim(hostname, "[]"), port)
+}
+
+// clientCertificate reads the client certificate from PEM files or a PKCS#12 bundle.
+func clientCertificate(cfg ClientConfig) (tls.Certificate, error) {
+	if cfg.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return cert, fmt.Errorf("loading client certificate %s: %w", cfg.ClientCertFile, err)
+		}
+		return cert, nil
+	}
+
+	bs, err := os.ReadFile(cfg.ClientCertFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("reading client certificate %s: %w", cfg.ClientCertFile, err)
+	}
+	key, leaf, chain, err := pkcs12.DecodeChain(bs, cfg.ClientCertPassword)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("decoding PKCS#12 client certificate %s: %w", cfg.ClientCertFile, err)
+	}
+	cert := tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, c := range chain {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return cert, nil
+}
+
+// pinVerifier returns a tls.Config.VerifyConnection function which requires the server's leaf
+// certificate to match one of the public key or certificate pins.
+func pinVerifier(publicKeys, certificates []string) (func(tls.ConnectionState) error, error) {
+	keyPins := make(map[[sha256.Size]byte]bool)
+	for _, pin := range publicKeys {
+		bs, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256//"))
+		if err != nil || len(bs) != sha256.Size {
+			return nil, fmt.Errorf("invalid public key pin %q", pin)
+		}
+		keyPins[[sha256.Size]byte(bs)] = true
+	}
+
+	certPins := make(map[[sha256.Size]byte]bool)
+	for _, pin := range certificates {
+		bs, err := hex.DecodeString(strings.ReplaceAll(pin, ":", ""))
+		if err != nil || len(bs) != sha256.Size {
+			return nil, fmt.Errorf("invalid certificate pin %q", pin)
+		}
+		certPins[[sha256.Size]byte(bs)] = true
+	}
+
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("certificate pinning: no server certificate")
+		}
+		leaf := cs.PeerCertificates[0]
+		if keyPins[sha256.Sum256(leaf.RawSubjectPublicKeyInfo)] || certPins[sha256.Sum256(leaf.Raw)] {
+			return nil
+		}
+		return fmt.Errorf("certificate pinning: %s does not match any pin", leaf.Subject)
+	}, nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestTLSConfig(t *testing.T) {
+	t.Run("none", func(t *testing.T) {
+		mode, conf, err := tlsConfig(ClientConfig{Hostname: "ftp.example.com"})
+		require.NoError(t, err)
+		require.Equal(t, TLSModeNone, mode)
+		require.Nil(t, conf)
+	})
+
+	t.Run("TLSConfig enables implicit", func(t *testing.T) {
+		given := &tls.Config{MinVersion: tls.VersionTLS13}
+		mode, conf, err := tlsConfig(ClientConfig{
+			Hostname:  "ftp.example.com",
+			TLSConfig: given,
+		})
+		require.NoError(t, err)
+		require.Equal(t, TLSModeImplicit, mode)
+		require.Equal(t, "ftp.example.com", conf.ServerName)
+		require.Equal(t, uint16(tls.VersionTLS13), conf.MinVersion)
+		require.NotNil(t, conf.ClientSessionCache)
+
+		// The caller's config is left alone
+		require.Empty(t, given.ServerName)
+	})
+
+	t.Run("explicit with system roots", func(t *testing.T) {
+		mode, conf, err := tlsConfig(ClientConfig{
+			Hostname: "ftp.example.com:21",
+			TLSMode:  TLSModeExplicit,
+		})
+		require.NoError(t, err)
+		require.Equal(t, TLSModeExplicit, mode)
+		require.Nil(t, conf.RootCAs)
+		require.Equal(t, "ftp.example.com", conf.ServerName)
+		require.Equal(t, uint16(tls.VersionTLS12), conf.MinVersion)
+	})
+
+	t.Run("none with TLS settings", func(t *testing.T) {
+		_, _, err := tlsConfig(ClientConfig{
+			TLSMode:   TLSModeNone,
+			TLSConfig: &tls.Config{},
+		})
+		require.ErrorContains(t, err, "TLS settings provided with TLSMode none")
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		_, _, err := tlsConfig(ClientConfig{
+			TLSMode: "ssl",
+		})
+		require.ErrorContains(t, err, `unknown TLSMode "ssl"`)
+	})
+
+	t.Run("missing CAFile", func(t *testing.T) {
+		_, _, err := tlsConfig(ClientConfig{
+			TLSMode: TLSModeImplicit,
+			CAFile:  filepath.Join("testdata", "missing.pem"),
+		})
+		require.ErrorContains(t, err, "failed to read")
+	})
+}
+
+func TestAddress(t *testing.T) {
+	require.Equal(t, "ftp.example.com:2121", address("ftp.example.com:2121", TLSModeImplicit))
+	require.Equal(t, "ftp.example.com:21", address("ftp.example.com", TLSModeNone))
+	require.Equal(t, "ftp.example.com:21", address("ftp.example.com", TLSModeExplicit))
+	require.Equal(t, "ftp.example.com:990", address("ftp.example.com", TLSModeImplicit))
+	require.Equal(t, "[::1]:21", address("::1", TLSModeNone))
+	require.Equal(t, "[::1]:990", address("[::1]", TLSModeImplicit))
+}
+
+func TestTLSConfig__ClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := generateCertificate(t)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client.key")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	t.Run("PEM", func(t *testing.T) {
+		_, conf, err := tlsConfig(ClientConfig{
+			Hostname:       "ftp.example.com",
+			TLSMode:        TLSModeExplicit,
+			ClientCertFile: certPath,
+			ClientKeyFile:  keyPath,
+		})
+		require.NoError(t, err)
+		require.Len(t, conf.Certificates, 1)
+		require.Equal(t, cert.Raw, conf.Certificates[0].Certificate[0])
+	})
+
+	t.Run("PKCS#12", func(t *testing.T) {
+		bs, err := pkcs12.Modern.Encode(key, cert, nil, "secret")
+		require.NoError(t, err)
+
+		p12Path := filepath.Join(dir, "client.p12")
+		require.NoError(t, os.WriteFile(p12Path, bs, 0600))
+
+		_, conf, err := tlsConfig(ClientConfig{
+			Hostname:           "ftp.example.com",
+			ClientCertFile:     p12Path,
+			ClientCertPassword: "secret",
+		})
+		require.NoError(t, err)
+		require.Len(t, conf.Certificates, 1)
+		require.Equal(t, cert.Raw, conf.Certificates[0].Certificate[0])
+
+		_, _, err = tlsConfig(ClientConfig{
+			Hostname:           "ftp.example.com",
+			ClientCertFile:     p12Path,
+			ClientCertPassword: "wrong",
+		})
+		require.ErrorContains(t, err, "decoding PKCS#12 client certificate")
+	})
+
+	t.Run("none with client certificate", func(t *testing.T) {
+		_, _, err := tlsConfig(ClientConfig{
+			TLSMode:        TLSModeNone,
+			ClientCertFile: certPath,
+			ClientKeyFile:  keyPath,
+		})
+		require.ErrorContains(t, err, "TLS settings provided with TLSMode none")
+	})
+}
+
+func TestTLSConfig__Pinning(t *testing.T) {
+	cert, _ := generateCertificate(t)
+	other, _ := generateCertificate(t)
+
+	spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	state := func(c *x509.Certificate) tls.ConnectionState {
+		return tls.ConnectionState{PeerCertificates: []*x509.Certificate{c}}
+	}
+
+	t.Run("public key", func(t *testing.T) {
+		_, conf, err := tlsConfig(ClientConfig{
+			Hostname:         "ftp.example.com",
+			PinnedPublicKeys: []string{"sha256//" + base64.StdEncoding.EncodeToString(spki[:])},
+		})
+		require.NoError(t, err)
+		require.NoError(t, conf.VerifyConnection(state(cert)))
+		require.ErrorContains(t, conf.VerifyConnection(state(other)), "does not match any pin")
+	})
+
+	t.Run("certificate", func(t *testing.T) {
+		var called bool
+		_, conf, err := tlsConfig(ClientConfig{
+			Hostname:           "ftp.example.com",
+			PinnedCertificates: []string{hex.EncodeToString(fingerprint[:])},
+			TLSConfig: &tls.Config{
+				VerifyConnection: func(tls.ConnectionState) error {
+					called = true
+					return nil
+				},
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, conf.VerifyConnection(state(cert)))
+		require.True(t, called)
+		require.ErrorContains(t, conf.VerifyConnection(state(other)), "does not match any pin")
+		require.ErrorContains(t, conf.VerifyConnection(tls.ConnectionState{}), "no server certificate")
+	})
+
+	t.Run("invalid pins", func(t *testing.T) {
+		_, _, err := tlsConfig(ClientConfig{
+			PinnedPublicKeys: []string{"not-base64"},
+		})
+		require.ErrorContains(t, err, "invalid public key pin")
+
+		_, _, err = tlsConfig(ClientConfig{
+			PinnedCertificates: []string{"AB:CD"},
+		})
+		require.ErrorContains(t, err, "invalid certificate pin")
+	})
+}
+
+func generateCertificate(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "go-ftp test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, key
+}
@@ -0,0 +1,251 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/textproto"
+	"os"
+	"path/filepath"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// AtomicUploadConfig sets the temporary name files are written to before being renamed.
+// The zero value writes a hidden file ending in .tmp next to the final file, e.g. .ach.txt.tmp
+type AtomicUploadConfig struct {
+	// Prefix and Suffix are added to the filename while it's uploaded.
+	Prefix string
+	Suffix string
+
+	// Dir is a directory, relative to the directory of the uploaded file, to write the file
+	// into while it's uploaded (e.g. ".uploading"). It's created when missing.
+	Dir string
+}
+
+// AtomicUpload writes the file under a temporary name and renames it to the final path once the
+// remote size matches the bytes sent, so other readers never see a partial file. Any existing
+// file at the path is replaced, unless the server refuses to rename over it which fails the
+// upload and keeps the existing file. The temporary file is removed when the upload fails.
+func AtomicUpload(cfg AtomicUploadConfig) UploadOption {
+	return func(o *uploadOptions) {
+		o.atomic = &cfg
+	}
+}
+
+func (cfg AtomicUploadConfig) tempName(filename string) string {
+	prefix, suffix := cfg.Prefix, cfg.Suffix
+	if prefix == "" && suffix == "" && cfg.Dir == "" {
+		prefix, suffix = ".", ".tmp"
+	}
+	return filepath.Join(cfg.Dir, prefix+filename+suffix)
+}
+
+// storAtomic uploads contents into filename through a temporary file. It expects the
+// connection to be in the directory of filename.
+func storAtomic(conn *serverConn, filename string, contents io.Reader, cfg AtomicUploadConfig) (err error) {
+	tempName := cfg.tempName(filename)
+	if cfg.Dir != "" {
+		if err := mkdirAll(conn, cfg.Dir); err != nil {
+			return fmt.Errorf("creating temporary dir: %w", err)
+		}
+	}
+	defer func() {
+		// Remove the partial file, unless the connection was lost
+		if err != nil && !conn.isAborted() {
+			conn.Delete(tempName)
+		}
+	}()
+
+	counter := &countingReader{Reader: contents}
+	err = conn.Stor(tempName, counter)
+	if err != nil {
+		return err
+	}
+
+	err = verifySize(conn, tempName, counter.n)
+	if err != nil {
+		return err
+	}
+
+	// Existing files aren't removed when the server refuses to replace them, as the upload
+	// would be lost along with the file if the rename still failed
+	err = conn.Rename(tempName, filename)
+	if err != nil {
+		return fmt.Errorf("rename %s to %s failed: %w", tempName, filename, err)
+	}
+	return nil
+}
+
+// ResumeUpload continues an interrupted upload instead of starting over. The size of the file
+// on the server is skipped from the contents, which must implement io.Seeker, and the rest is
+// written from that offset with REST and STOR.
+func ResumeUpload() UploadOption {
+	return func(o *uploadOptions) {
+		o.resume = true
+	}
+}
+
+// storResume uploads the part of contents missing from filename. It expects the connection
+// to be in the directory of filename.
+func storResume(conn *serverConn, filename string, contents io.Reader) error {
+	seeker, ok := contents.(io.Seeker)
+	if !ok {
+		return errors.New("resuming an upload requires contents to implement io.Seeker")
+	}
+	size, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("finding size of contents: %w", err)
+	}
+
+	// Only start over when the server says the file is missing
+	offset, err := remoteSize(conn, filename)
+	if err != nil {
+		if !isNotExistReply(err) {
+			return err
+		}
+		offset = 0
+	}
+	if offset > size {
+		return fmt.Errorf("%s is %d bytes on the server, which is larger than the %d bytes to upload", filename, offset, size)
+	}
+
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("skipping %d uploaded bytes: %w", offset, err)
+	}
+	if err := conn.StorFrom(filename, contents, uint64(offset)); err != nil {
+		return err
+	}
+	return verifySize(conn, filename, size)
+}
+
+// verifySize checks the file at path on the server is size bytes long.
+func verifySize(conn *serverConn, path string, size int64) error {
+	remote, err := remoteSize(conn, path)
+	if err != nil {
+		return fmt.Errorf("verifying size of %s: %w", path, err)
+	}
+	if remote != size {
+		return fmt.Errorf("verifying size of %s: %d bytes on server but %d bytes were sent", path, remote, size)
+	}
+	return nil
+}
+
+// remoteSize returns the size of the file at path from SIZE. Files are only looked up with
+// statEntry when the server doesn't support SIZE, as listings often leave out hidden files
+// such as temporary uploads.
+func remoteSize(conn *serverConn, path string) (int64, error) {
+	size, err := conn.FileSize(path)
+	if err == nil || !isNotImplemented(err) {
+		return size, err
+	}
+	entry, err := statEntry(conn, path)
+	if err != nil {
+		return 0, err
+	}
+	return int64(entry.Size), nil
+}
+
+// isNotImplemented reports whether err is a reply saying the server doesn't support the command.
+func isNotImplemented(err error) bool {
+	var tpErr *textproto.Error
+	if !errors.As(err, &tpErr) {
+		return false
+	}
+	switch tpErr.Code {
+	case ftp.StatusBadCommand, ftp.StatusNotImplemented, ftp.StatusNotImplementedParameter:
+		return true
+	}
+	return false
+}
+
+// isNotExistReply reports whether err says the file is missing. Servers reply to SIZE with 550
+// for missing files, so 550 replies which don't give another reason for failing count too.
+func isNotExistReply(err error) bool {
+	var tpErr *textproto.Error
+	if !errors.As(err, &tpErr) {
+		return errors.Is(err, fs.ErrNotExist)
+	}
+	if tpErr.Code != ftp.StatusFileUnavailable {
+		return false
+	}
+	match := replyError(tpErr.Code, tpErr.Msg)
+	return match == nil || match == fs.ErrNotExist
+}
+
+// countingReader counts the bytes read from Reader, which are also passed to observe when
+// it's set.
+type countingReader struct {
+	io.Reader
+
+	n       int64
+	observe func(n int)
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	if r.observe != nil {
+		r.observe(n)
+	}
+	return n, err
+}
+
+// checkOpenFlags verifies flag describes writing a file, which is all OpenFile supports.
+func checkOpenFlags(flag int, options uploadOptions) error {
+	switch {
+	case flag&(os.O_RDONLY|os.O_WRONLY|os.O_RDWR) != os.O_WRONLY:
+		return errors.New("only os.O_WRONLY is supported, use Reader to read files")
+	case flag&(os.O_APPEND|os.O_TRUNC) == 0:
+		return errors.New("os.O_APPEND or os.O_TRUNC is required")
+	case flag&os.O_APPEND != 0 && options.atomic != nil:
+		return errors.New("AtomicUpload can't be used with os.O_APPEND")
+	case options.resume:
+		return errors.New("ResumeUpload requires UploadFile")
+	}
+	return nil
+}
+
+// fileWriter streams writes into an upload which runs until the writer is closed.
+type fileWriter struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+// newFileWriter starts upload in a goroutine reading from the returned writer.
+func newFileWriter(upload func(r io.Reader) error) *fileWriter {
+	pr, pw := io.Pipe()
+	w := &fileWriter{
+		pw:   pw,
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(w.done)
+
+		w.err = upload(pr)
+
+		// Fail writes when the upload stopped early
+		pr.CloseWithError(cmp.Or(w.err, errUploadStopped))
+	}()
+	return w
+}
+
+var errUploadStopped = errors.New("upload stopped before the writer was closed")
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close finishes the upload and returns its error.
+func (w *fileWriter) Close() error {
+	w.pw.Close()
+	<-w.done
+	return w.err
+}
@@ -0,0 +1,267 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moov-io/go-ftp/internal/jsonfile"
+	"github.com/moov-io/go-ftp/internal/walk"
+)
+
+// WatchOp is the kind of change a WatchEvent reports.
+type WatchOp string
+
+const (
+	FileCreated  WatchOp = "created"
+	FileModified WatchOp = "modified"
+	FileRemoved  WatchOp = "removed"
+
+	// FileReady is sent once a file has finished uploading, see ReadyOptions.
+	FileReady WatchOp = "ready"
+)
+
+// WatchEvent is a change to a file found by Watch.
+type WatchEvent struct {
+	Op WatchOp
+
+	// Path is the remote path of the file, which is the watched directory joined with the
+	// file's path within it.
+	Path string
+
+	// File is the state of the file when it was seen, or before it was removed.
+	File FileState
+
+	// Err is set, without an Op, when polling the directory failed. Watch tries again on the
+	// next poll.
+	Err error
+}
+
+// FileState is the size and modification time of a file, which Watch compares between polls.
+type FileState struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+
+	// Ready is set once FileReady was sent for this size and modification time.
+	Ready bool `json:"ready,omitempty"`
+}
+
+// SnapshotStore keeps the files Watch saw on its last poll of a directory, so restarting
+// doesn't announce files again.
+type SnapshotStore interface {
+	// Load returns the files last saved for dir, which is empty when nothing was saved.
+	Load(ctx context.Context, dir string) (map[string]FileState, error)
+
+	// Save replaces the files of dir.
+	Save(ctx context.Context, dir string, files map[string]FileState) error
+}
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+	// Interval is how often the directory is polled, which defaults to one minute.
+	Interval time.Duration
+
+	// Store keeps the files seen between polls. Without a Store they're kept in memory, so
+	// every file in the directory is reported as created on the first poll.
+	Store SnapshotStore
+
+	// Ready sends a FileReady event once a file is done being uploaded, so it isn't opened
+	// half-written.
+	Ready *ReadyOptions
+}
+
+// Watch polls dir and its subdirectories and sends an event on the returned channel for each
+// file which was created, modified (its size or modification time changed) or removed since
+// the previous poll. Events of a poll are sorted by path.
+//
+// The first poll happens right away and compares against the snapshot in WatchOptions.Store.
+// A poll's snapshot is saved once all of its events have been received, so files are
+// announced again after a restart when their events weren't received. The channel is closed
+// once ctx is done.
+func Watch(ctx context.Context, client ClientContext, dir string, opts WatchOptions) (<-chan WatchEvent, error) {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	store := opts.Store
+	if store == nil {
+		store = NewMemorySnapshotStore()
+	}
+
+	previous, err := store.Load(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("watch %s: loading snapshot: %w", dir, err)
+	}
+
+	var ready *readyTracker
+	if opts.Ready != nil {
+		ready = newReadyTracker(*opts.Ready, previous)
+	}
+
+	events := make(chan WatchEvent)
+	go func() {
+		defer close(events)
+
+		send := func(event WatchEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			current, err := snapshot(ctx, client, dir)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				if !send(WatchEvent{Err: fmt.Errorf("watch %s: %w", dir, err)}) {
+					return
+				}
+			default:
+				changes := diffSnapshots(previous, current)
+				if ready != nil {
+					changes = append(changes, ready.update(current, time.Now())...)
+					slices.SortStableFunc(changes, func(a, b WatchEvent) int {
+						return strings.Compare(a.Path, b.Path)
+					})
+				}
+				for _, event := range changes {
+					if !send(event) {
+						return
+					}
+				}
+				if err := store.Save(ctx, dir, current); err != nil {
+					if !send(WatchEvent{Err: fmt.Errorf("watch %s: saving snapshot: %w", dir, err)}) {
+						return
+					}
+				} else {
+					previous = current
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// snapshot returns the files in dir and its subdirectories by their remote path.
+func snapshot(ctx context.Context, client ClientContext, dir string) (map[string]FileState, error) {
+	files := make(map[string]FileState)
+	err := walk.Files(ctx, client, dir, func(name string, info fs.FileInfo) error {
+		files[path.Join(dir, name)] = FileState{Size: info.Size(), ModTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+func diffSnapshots(previous, current map[string]FileState) []WatchEvent {
+	var events []WatchEvent
+	for name, file := range current {
+		before, existed := previous[name]
+		switch {
+		case !existed:
+			events = append(events, WatchEvent{Op: FileCreated, Path: name, File: file})
+		case before.Size != file.Size || !before.ModTime.Equal(file.ModTime):
+			events = append(events, WatchEvent{Op: FileModified, Path: name, File: file})
+		}
+	}
+	for name, file := range previous {
+		if _, exists := current[name]; !exists {
+			events = append(events, WatchEvent{Op: FileRemoved, Path: name, File: file})
+		}
+	}
+	slices.SortFunc(events, func(a, b WatchEvent) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return events
+}
+
+// NewMemorySnapshotStore returns a SnapshotStore which keeps snapshots in memory.
+func NewMemorySnapshotStore() SnapshotStore {
+	return &memorySnapshotStore{snapshots: make(map[string]map[string]FileState)}
+}
+
+type memorySnapshotStore struct {
+	mu        sync.Mutex
+	snapshots map[string]map[string]FileState
+}
+
+func (s *memorySnapshotStore) Load(ctx context.Context, dir string) (map[string]FileState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return maps.Clone(s.snapshots[dir]), nil
+}
+
+func (s *memorySnapshotStore) Save(ctx context.Context, dir string, files map[string]FileState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.snapshots[dir] = maps.Clone(files)
+	return nil
+}
+
+// NewFileSnapshotStore returns a SnapshotStore which keeps snapshots in a JSON file at path,
+// which is created when it doesn't exist. Snapshots of several directories can share a file,
+// but only within one process.
+func NewFileSnapshotStore(path string) SnapshotStore {
+	return &fileSnapshotStore{path: path}
+}
+
+type fileSnapshotStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (s *fileSnapshotStore) Load(ctx context.Context, dir string) (map[string]FileState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshots, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return snapshots[dir], nil
+}
+
+func (s *fileSnapshotStore) Save(ctx context.Context, dir string, files map[string]FileState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshots, err := s.read()
+	if err != nil {
+		return err
+	}
+	snapshots[dir] = files
+
+	return jsonfile.Write(s.path, snapshots)
+}
+
+func (s *fileSnapshotStore) read() (map[string]map[string]FileState, error) {
+	snapshots := make(map[string]map[string]FileState)
+	if err := jsonfile.Read(s.path, &snapshots); err != nil {
+		return nil, fmt.Errorf("reading snapshots: %w", err)
+	}
+	return snapshots, nil
+}
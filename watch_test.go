@@ -0,0 +1,102 @@
+// Copyright 2023 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_ftp
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := NewMockClient(t)
+	upload := func(path, contents string) {
+		t.Helper()
+		require.NoError(t, client.UploadFile(path, io.NopCloser(strings.NewReader(contents)), CreateParentDirs()))
+	}
+	upload("/inbound/a.txt", "a")
+
+	store := NewFileSnapshotStore(filepath.Join(t.TempDir(), "snapshots.json"))
+	events, err := Watch(ctx, client, "/inbound", WatchOptions{
+		Interval: 10 * time.Millisecond,
+		Store:    store,
+	})
+	require.NoError(t, err)
+
+	event := <-events
+	require.Equal(t, FileCreated, event.Op)
+	require.Equal(t, "/inbound/a.txt", event.Path)
+	require.Equal(t, int64(1), event.File.Size)
+
+	upload("/inbound/returns/b.txt", "b")
+	event = <-events
+	require.Equal(t, FileCreated, event.Op)
+	require.Equal(t, "/inbound/returns/b.txt", event.Path)
+
+	upload("/inbound/a.txt", "aaa")
+	event = <-events
+	require.Equal(t, FileModified, event.Op)
+	require.Equal(t, "/inbound/a.txt", event.Path)
+	require.Equal(t, int64(3), event.File.Size)
+
+	require.NoError(t, client.Delete("/inbound/returns/b.txt"))
+	event = <-events
+	require.Equal(t, FileRemoved, event.Op)
+	require.Equal(t, "/inbound/returns/b.txt", event.Path)
+
+	cancel()
+	for range events {
+	}
+
+	// Restarting with the same store only announces changes
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	upload("/inbound/c.txt", "c")
+	events, err = Watch(ctx, client, "/inbound", WatchOptions{Store: store})
+	require.NoError(t, err)
+
+	event = <-events
+	require.Equal(t, FileCreated, event.Op)
+	require.Equal(t, "/inbound/c.txt", event.Path)
+
+	// Failed polls are reported and retried
+	client.ReadDirErr = io.ErrUnexpectedEOF
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err = Watch(ctx, client, "/inbound", WatchOptions{})
+	require.NoError(t, err)
+	event = <-events
+	require.ErrorIs(t, event.Err, io.ErrUnexpectedEOF)
+	require.Empty(t, event.Op)
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	now := time.Now()
+	previous := map[string]FileState{
+		"/a.txt": {Size: 1, ModTime: now},
+		"/b.txt": {Size: 1, ModTime: now},
+		"/c.txt": {Size: 1, ModTime: now},
+	}
+	current := map[string]FileState{
+		"/a.txt": {Size: 1, ModTime: now},
+		"/b.txt": {Size: 1, ModTime: now.Add(time.Minute)},
+		"/d.txt": {Size: 2, ModTime: now},
+	}
+	require.Equal(t, []WatchEvent{
+		{Op: FileModified, Path: "/b.txt", File: current["/b.txt"]},
+		{Op: FileRemoved, Path: "/c.txt", File: previous["/c.txt"]},
+		{Op: FileCreated, Path: "/d.txt", File: current["/d.txt"]},
+	}, diffSnapshots(previous, current))
+}